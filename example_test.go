@@ -1,12 +1,23 @@
 package simpleviper_test
 
 import (
+	"bytes"
+	"context"
+	"embed"
+	"encoding/base64"
+	"errors"
 	"fmt"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"slices"
 	"strings"
+	"time"
 
 	"github.com/andrewheberle/simpleviper"
+	"github.com/fsnotify/fsnotify"
 	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
 )
 
 // This example demonstrates values coming from the command line, defaults, environment variables and a configuration file.
@@ -186,3 +197,1567 @@ func ExampleViperlet_Init_withEnvPrefixwithEnvKeyReplacer() {
 	// from env var
 	// from command line
 }
+
+// This example demonstrates loading a config file that has no extension by explicitly setting the config type.
+func ExampleWithConfigType() {
+	var exampleVar string
+
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringVar(&exampleVar, "example", "", "Example flag")
+	fs.Parse([]string{})
+
+	if err := simpleviper.New(simpleviper.WithConfig("testdata/config"), simpleviper.WithConfigType("yaml")).Init(fs); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	fmt.Println(exampleVar)
+	// Output: from extensionless config file
+}
+
+// This example demonstrates searching multiple paths for a config file by name.
+func ExampleWithConfigPaths() {
+	var exampleVar string
+
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringVar(&exampleVar, "example", "", "Example flag")
+	fs.Parse([]string{})
+
+	if err := simpleviper.New(simpleviper.WithConfigName("search"), simpleviper.WithConfigPaths("testdata/missing", "testdata")).Init(fs); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	fmt.Println(exampleVar)
+	// Output: from config file found in search paths
+}
+
+// This example demonstrates searching for an optional config file that is not found in any of the search paths.
+func ExampleWithOptionalConfigName() {
+	var exampleVar string
+
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringVar(&exampleVar, "example", "", "Example flag")
+	fs.Parse([]string{"--example", "from command line"})
+
+	if err := simpleviper.New(simpleviper.WithOptionalConfigName("missing"), simpleviper.WithConfigPaths("testdata")).Init(fs); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	fmt.Println(exampleVar)
+	// Output: from command line
+}
+
+// This example demonstrates setting default values, including for keys that have no corresponding flag.
+func ExampleWithDefaults() {
+	var example4, example5 string
+
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringVar(&example4, "example4", "", "Example flag 4")
+	fs.StringVar(&example5, "example5", "from flag default", "Example flag 5")
+	fs.Parse([]string{})
+
+	v := simpleviper.New(
+		simpleviper.WithConfig("example.yml"),
+		simpleviper.WithDefaults(map[string]any{
+			"example4":       "config file will take precedence",
+			"example5":       "from defaults",
+			"server.timeout": "30s",
+		}),
+	)
+	if err := v.Init(fs); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	fmt.Println(example4)
+	fmt.Println(example5)
+	fmt.Println(v.Viper().GetDuration("server.timeout"))
+	// Output:
+	// from config file
+	// from defaults
+	// 30s
+}
+
+// This example demonstrates an empty value in a config file clearing a non-empty flag default.
+func ExampleViperlet_Init_emptyConfigValue() {
+	var exampleVar string
+
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringVar(&exampleVar, "example", "cleared by config file", "Example flag")
+	fs.Parse([]string{})
+
+	if err := simpleviper.New(simpleviper.WithConfig("testdata/empty.yml")).Init(fs); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	fmt.Printf("%q\n", exampleVar)
+	// Output: ""
+}
+
+// This example demonstrates decoding the merged configuration into a struct.
+func ExampleViperlet_Unmarshal() {
+	var config struct {
+		Example1 string `mapstructure:"example1"`
+		Example4 string `mapstructure:"example4"`
+	}
+
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.String("example1", "", "Example flag 1")
+	fs.Parse([]string{"--example1", "from command line"})
+
+	v := simpleviper.New(simpleviper.WithConfig("example.yml"))
+	if err := v.Init(fs); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	if err := v.Unmarshal(&config); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		return
+	}
+
+	fmt.Println(config.Example1)
+	fmt.Println(config.Example4)
+	// Output:
+	// from command line
+	// from config file
+}
+
+// This example demonstrates watching the config file for changes.
+func ExampleWithWatch() {
+	v := simpleviper.New(simpleviper.WithConfig("config.yml"), simpleviper.WithWatch(func(e fsnotify.Event) {
+		fmt.Printf("config file changed: %s\n", e.Name)
+	}))
+
+	if err := v.Init(); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+	defer v.Close()
+
+	// a real program would continue running here, with lookups via v.Viper() seeing any changes
+}
+
+// This example demonstrates merging multiple config files, where later files take precedence.
+func ExampleWithMergeConfig() {
+	var example1, example2 string
+
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringVar(&example1, "example1", "", "Example flag 1")
+	fs.StringVar(&example2, "example2", "", "Example flag 2")
+	fs.Parse([]string{})
+
+	if err := simpleviper.New(simpleviper.WithMergeConfig("testdata/base.yml", "testdata/override.yml")).Init(fs); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	fmt.Println(example1)
+	fmt.Println(example2)
+	// Output:
+	// from base config file
+	// from override config file
+}
+
+// This example demonstrates merging multiple config files where missing files are skipped.
+func ExampleWithOptionalMergeConfig() {
+	var example1, example2 string
+
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringVar(&example1, "example1", "", "Example flag 1")
+	fs.StringVar(&example2, "example2", "", "Example flag 2")
+	fs.Parse([]string{})
+
+	if err := simpleviper.New(simpleviper.WithOptionalMergeConfig("testdata/missing.yml", "testdata/base.yml", "testdata/override.yml")).Init(fs); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	fmt.Println(example1)
+	fmt.Println(example2)
+	// Output:
+	// from base config file
+	// from override config file
+}
+
+// This example demonstrates reading config from an io.Reader along with environment variables.
+func ExampleWithConfigReader() {
+	var example1, example2 string
+
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringVar(&example1, "example1", "", "Example flag 1")
+	fs.StringVar(&example2, "example2", "", "Example flag 2")
+	fs.Parse([]string{})
+
+	// set some env vars
+	os.Setenv("EXAMPLE2", "env var overrides config")
+	defer os.Unsetenv("EXAMPLE2")
+
+	config := strings.NewReader("example1: from config reader\nexample2: from config reader\n")
+
+	if err := simpleviper.New(simpleviper.WithEnv(), simpleviper.WithConfigReader(config, "yaml")).Init(fs); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	fmt.Println(example1)
+	fmt.Println(example2)
+	// Output:
+	// from config reader
+	// env var overrides config
+}
+
+// This example demonstrates that a cancelled context aborts initialisation.
+func ExampleViperlet_InitContext() {
+	var exampleVar string
+
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringVar(&exampleVar, "example", "", "Example flag")
+	fs.Parse([]string{})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := simpleviper.New(simpleviper.WithConfig("example.yml")).InitContext(ctx, fs); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	// this is not executed
+	fmt.Println(exampleVar)
+	// Output: error: context canceled
+}
+
+// This example demonstrates binding only specific environment variables.
+func ExampleWithEnvVars() {
+	var example1, example2 string
+
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringVar(&example1, "example1", "", "Example flag 1")
+	fs.StringVar(&example2, "example2", "from default value", "Example flag 2")
+	fs.Parse([]string{})
+
+	// set some env vars
+	os.Setenv("CMD_EXAMPLE1", "from env var")
+	os.Setenv("CMD_EXAMPLE2", "env var is not bound")
+	defer os.Unsetenv("CMD_EXAMPLE1")
+	defer os.Unsetenv("CMD_EXAMPLE2")
+
+	if err := simpleviper.New(simpleviper.WithEnvPrefix("cmd"), simpleviper.WithEnvVars("example1")).Init(fs); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	fmt.Println(example1)
+	fmt.Println(example2)
+	// Output:
+	// from env var
+	// from default value
+}
+
+// This example demonstrates providing the flagset at construction time.
+func ExampleWithFlagSet() {
+	var exampleVar string
+
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringVar(&exampleVar, "example4", "", "Example flag")
+	fs.Parse([]string{})
+
+	v := simpleviper.New(simpleviper.WithFlagSet(fs), simpleviper.WithConfig("example.yml"))
+
+	// later on Init is called without passing the flagset again
+	if err := v.Init(); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	fmt.Println(exampleVar)
+	// Output: from config file
+}
+
+// This example demonstrates a flag set on the command line taking precedence over the same value in a config file.
+func ExampleViperlet_Init_commandLinePrecedence() {
+	var exampleVar string
+
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringVar(&exampleVar, "example4", "", "Example flag")
+	fs.Parse([]string{"--example4", "from command line"})
+
+	if err := simpleviper.New(simpleviper.WithConfig("example.yml")).Init(fs); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	fmt.Println(exampleVar)
+	// Output: from command line
+}
+
+// This example demonstrates retrieving all settings after Init.
+func ExampleViperlet_AllSettings() {
+	v := simpleviper.New(simpleviper.WithConfig("testdata/base.yml"))
+	if err := v.Init(); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	fmt.Println(v.AllSettings())
+	// Output: map[example1:from base config file example2:overridden by override config file]
+}
+
+// This example demonstrates matching the error returned when a config file cannot be read.
+func ExampleViperlet_Init_readConfigError() {
+	err := simpleviper.New(simpleviper.WithConfig("testdata/missing.yml")).Init()
+
+	fmt.Println(errors.Is(err, simpleviper.ErrReadConfig))
+	fmt.Println(errors.Is(err, os.ErrNotExist))
+	// Output:
+	// true
+	// true
+}
+
+// This example demonstrates validating the merged configuration.
+func ExampleWithValidate() {
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.String("token", "", "Token")
+	fs.String("token-file", "", "Token file")
+	fs.Parse([]string{})
+
+	err := simpleviper.New(simpleviper.WithValidate(func(v *viper.Viper) error {
+		if v.GetString("token") == "" && v.GetString("token-file") == "" {
+			return fmt.Errorf("either --token or --token-file must be set")
+		}
+
+		return nil
+	})).Init(fs)
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	// this is not executed
+	fmt.Println("valid")
+	// Output: error: either --token or --token-file must be set
+}
+
+// This example demonstrates writing the effective config to a file.
+func ExampleViperlet_WriteConfigAs() {
+	dir, err := os.MkdirTemp("", "example")
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.String("example", "from default value", "Example flag")
+	fs.Parse([]string{})
+
+	v := simpleviper.New()
+	if err := v.Init(fs); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	config := filepath.Join(dir, "config.yml")
+	if err := v.WriteConfigAs(config); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		return
+	}
+
+	b, _ := os.ReadFile(config)
+	fmt.Print(string(b))
+	// Output: example: from default value
+}
+
+// This example demonstrates strict mode returning an error when there is nothing to bind.
+func ExampleWithStrict() {
+	if err := simpleviper.New(simpleviper.WithStrict()).Init(); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	// this is not executed
+	fmt.Println("success")
+	// Output: error: nothing to bind
+}
+
+// This example demonstrates type-safe lookups of values.
+func ExampleGet() {
+	v := simpleviper.New(simpleviper.WithDefaults(map[string]any{
+		"server.timeout": "30s",
+		"server.name":    "example",
+	}))
+	if err := v.Init(); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	timeout, err := simpleviper.Get[time.Duration](v, "server.timeout")
+	fmt.Println(timeout, err)
+
+	_, err = simpleviper.Get[int](v, "server.name")
+	fmt.Println(errors.Is(err, simpleviper.ErrTypeMismatch))
+
+	_, err = simpleviper.Get[chan int](v, "server.name")
+	fmt.Println(errors.Is(err, simpleviper.ErrUnsupportedType))
+	// Output:
+	// 30s <nil>
+	// true
+	// true
+}
+
+// This example demonstrates the default env key replacer mapping dots and dashes to underscores.
+func ExampleWithEnvKeyReplacerDefault() {
+	var exampleVar string
+
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringVar(&exampleVar, "server.listen-address", "", "Example flag")
+	fs.Parse([]string{})
+
+	// set some env vars
+	os.Setenv("CMD_SERVER_LISTEN_ADDRESS", "from env var")
+	defer os.Unsetenv("CMD_SERVER_LISTEN_ADDRESS")
+
+	if err := simpleviper.New(simpleviper.WithEnvPrefix("cmd"), simpleviper.WithEnvKeyReplacerDefault()).Init(fs); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	fmt.Println(exampleVar)
+	// Output: from env var
+}
+
+// This example demonstrates resetting a Viperlet to run Init again with different env vars.
+func ExampleViperlet_Reset() {
+	v := simpleviper.New(simpleviper.WithEnvPrefix("cmd"))
+	defer os.Unsetenv("CMD_EXAMPLE")
+
+	for _, value := range []string{"from first env var", "from second env var"} {
+		var exampleVar string
+
+		// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+		fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+		fs.StringVar(&exampleVar, "example", "", "Example flag")
+		fs.Parse([]string{})
+
+		// reset and set a different env var value each time
+		v.Reset()
+		os.Setenv("CMD_EXAMPLE", value)
+
+		if err := v.Init(fs); err != nil {
+			fmt.Printf("error: %s\n", err)
+
+			// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+			return
+		}
+
+		fmt.Println(exampleVar)
+	}
+	// Output:
+	// from first env var
+	// from second env var
+}
+
+// This example demonstrates binding flags for different components under their own key prefix.
+func ExampleWithFlagKeyPrefix() {
+	var dbTimeout, cacheTimeout time.Duration
+
+	// create flagsets, which in a real program (not an example) would use pflag.ExitOnError
+	dbfs := pflag.NewFlagSet("db", pflag.ContinueOnError)
+	dbfs.DurationVar(&dbTimeout, "timeout", 0, "Database timeout")
+	dbfs.Parse([]string{})
+
+	cachefs := pflag.NewFlagSet("cache", pflag.ContinueOnError)
+	cachefs.DurationVar(&cacheTimeout, "timeout", 0, "Cache timeout")
+	cachefs.Parse([]string{})
+
+	// set some env vars
+	os.Setenv("CACHE_TIMEOUT", "5m")
+	defer os.Unsetenv("CACHE_TIMEOUT")
+
+	for prefix, fs := range map[string]*pflag.FlagSet{"db": dbfs, "cache": cachefs} {
+		v := simpleviper.New(
+			simpleviper.WithFlagKeyPrefix(prefix),
+			simpleviper.WithEnvKeyReplacerDefault(),
+			simpleviper.WithConfig("testdata/prefix.yml"),
+		)
+		if err := v.Init(fs); err != nil {
+			fmt.Printf("error: %s\n", err)
+
+			// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+			return
+		}
+	}
+
+	fmt.Println(dbTimeout)
+	fmt.Println(cacheTimeout)
+	// Output:
+	// 10s
+	// 5m0s
+}
+
+// This example demonstrates loading a config file that has no extension by explicitly setting the config format.
+func ExampleWithConfigFormat() {
+	var exampleVar string
+
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringVar(&exampleVar, "example", "", "Example flag")
+	fs.Parse([]string{})
+
+	if err := simpleviper.New(simpleviper.WithConfig("testdata/tomlconfig"), simpleviper.WithConfigFormat(simpleviper.FormatTOML)).Init(fs); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	fmt.Println(exampleVar)
+	// Output: from extensionless TOML config file
+}
+
+// This example demonstrates looking up environment variables using multiple prefixes.
+func ExampleWithEnvPrefixes() {
+	var example1, example2, example3 string
+
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringVar(&example1, "example1", "", "Example flag 1")
+	fs.StringVar(&example2, "example2", "", "Example flag 2")
+	fs.StringVar(&example3, "example3", "", "Example flag 3")
+	fs.Parse([]string{})
+
+	// set some env vars
+	os.Setenv("MYAPP_EXAMPLE1", "from new env var")
+	os.Setenv("OLDAPP_EXAMPLE1", "new env var will take precedence")
+	os.Setenv("OLDAPP_EXAMPLE2", "from old env var")
+	os.Setenv("EXAMPLE3", "env var without prefix is not used")
+	defer os.Unsetenv("MYAPP_EXAMPLE1")
+	defer os.Unsetenv("OLDAPP_EXAMPLE1")
+	defer os.Unsetenv("OLDAPP_EXAMPLE2")
+	defer os.Unsetenv("EXAMPLE3")
+
+	if err := simpleviper.New(simpleviper.WithEnvPrefixes("myapp", "oldapp")).Init(fs); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	fmt.Println(example1)
+	fmt.Println(example2)
+	fmt.Printf("%q\n", example3)
+	// Output:
+	// from new env var
+	// from old env var
+	// ""
+}
+
+// This example demonstrates loading environment variables from a dotenv file.
+func ExampleWithDotEnv() {
+	var example1, example2 string
+
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringVar(&example1, "example1", "", "Example flag 1")
+	fs.StringVar(&example2, "example2", "", "Example flag 2")
+	fs.Parse([]string{})
+
+	// set some env vars
+	os.Setenv("CMD_EXAMPLE2", "from env var")
+	defer os.Unsetenv("CMD_EXAMPLE1")
+	defer os.Unsetenv("CMD_EXAMPLE2")
+
+	if err := simpleviper.New(simpleviper.WithEnvPrefix("cmd"), simpleviper.WithDotEnv("testdata/example.env")).Init(fs); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	fmt.Println(example1)
+	fmt.Println(example2)
+	// Output:
+	// from dotenv file
+	// from env var
+}
+
+// This example demonstrates logging where each flag value came from.
+func ExampleWithLogger() {
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.String("example1", "", "Example flag 1")
+	fs.String("example3", "", "Example flag 3")
+	fs.String("example4", "", "Example flag 4")
+	fs.String("example5", "from default value", "Example flag 5")
+	fs.Parse([]string{"--example1", "from command line"})
+
+	// set some env vars
+	os.Setenv("EXAMPLE3", "from env var")
+	defer os.Unsetenv("EXAMPLE3")
+
+	// the time is removed from the output so it is consistent
+	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{
+		Level: slog.LevelDebug,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey {
+				return slog.Attr{}
+			}
+
+			return a
+		},
+	}))
+
+	_ = simpleviper.New(simpleviper.WithEnv(), simpleviper.WithConfig("example.yml"), simpleviper.WithLogger(logger)).Init(fs)
+	// Output:
+	// level=DEBUG msg="resolved flag value" flag=example1 key=example1 value="from command line" source=flag
+	// level=DEBUG msg="resolved flag value" flag=example3 key=example3 value="from env var" source=env
+	// level=DEBUG msg="resolved flag value" flag=example4 key=example4 value="from config file" source=config
+	// level=DEBUG msg="resolved flag value" flag=example5 key=example5 value="from default value" source=default
+}
+
+// This example demonstrates binding a single flag that is added after the Viperlet is created.
+func ExampleViperlet_BindFlag() {
+	v := simpleviper.New()
+
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.String("plugin", "", "Flag added by a plugin")
+	fs.Parse([]string{"--plugin", "from command line"})
+
+	if err := v.BindFlag(fs.Lookup("plugin")); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	fmt.Println(v.Viper().GetString("plugin"))
+	// Output: from command line
+}
+
+// This example demonstrates a list in a config file overriding the default of a slice flag.
+func ExampleViperlet_Init_sliceFlag() {
+	var hosts []string
+
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringSliceVar(&hosts, "hosts", []string{"localhost"}, "Example slice flag")
+	fs.Parse([]string{})
+
+	if err := simpleviper.New(simpleviper.WithConfig("testdata/slice.yml")).Init(fs); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	fmt.Println(len(hosts))
+	fmt.Println(strings.Join(hosts, "\n"))
+	// Output:
+	// 2
+	// a.example.com
+	// b.example.com,c.example.com
+}
+
+// This example demonstrates that an optional config file that exists but cannot be parsed is still treated as a fatal error.
+func ExampleViperlet_Init_invalidOptionalConfigFile() {
+	var exampleVar string
+
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringVar(&exampleVar, "example", "", "Example flag")
+	fs.Parse([]string{"--example", "from command line"})
+
+	if err := simpleviper.New(simpleviper.WithOptionalConfig("testdata/invalid.yml")).Init(fs); err != nil {
+		fmt.Println("error: config file invalid")
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	// this is not executed
+	fmt.Println(exampleVar)
+	// Output: error: config file invalid
+}
+
+// This example demonstrates using a custom key delimiter so keys can contain dots.
+func ExampleWithKeyDelimiter() {
+	v := simpleviper.New(simpleviper.WithKeyDelimiter("/"), simpleviper.WithConfig("testdata/delimiter.yml"))
+	if err := v.Init(); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	fmt.Println(v.Viper().GetString("server/host.name"))
+	// Output: from nested key containing a dot
+}
+
+// This example demonstrates getting a Viperlet scoped to a subtree of the config.
+func ExampleViperlet_Sub() {
+	v := simpleviper.New(simpleviper.WithConfig("testdata/prefix.yml"))
+	if err := v.Init(); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	db := v.Sub("db")
+	fmt.Println(db.AllSettings())
+	fmt.Println(v.Sub("missing") == nil)
+	// Output:
+	// map[timeout:10s]
+	// true
+}
+
+// This example demonstrates binding persistent and local flagsets, as used by cobra, where a flag is in both flagsets.
+func ExampleViperlet_Init_persistentFlags() {
+	var hosts []string
+	var example4 string
+
+	// create flagsets, which in a real program (not an example) would be provided by cobra
+	persistent := pflag.NewFlagSet("persistent", pflag.ContinueOnError)
+	persistent.StringSliceVar(&hosts, "hosts", nil, "Example persistent flag")
+
+	local := pflag.NewFlagSet("local", pflag.ContinueOnError)
+	local.StringVar(&example4, "example4", "", "Example local flag")
+
+	// cobra merges persistent flags into the flags of a command
+	local.AddFlagSet(persistent)
+	local.Parse([]string{})
+
+	if err := simpleviper.New(simpleviper.WithMergeConfig("example.yml", "testdata/slice.yml")).Init(persistent, local); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	fmt.Println(len(hosts))
+	fmt.Println(example4)
+	// Output:
+	// 2
+	// from config file
+}
+
+// This example demonstrates transforming values sourced from environment variables.
+func ExampleWithEnvTransform() {
+	var secret string
+
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringVar(&secret, "secret", "", "Example flag")
+	fs.Parse([]string{})
+
+	// set some env vars
+	os.Setenv("CMD_SECRET", base64.StdEncoding.EncodeToString([]byte("decoded from env var")))
+	defer os.Unsetenv("CMD_SECRET")
+
+	err := simpleviper.New(simpleviper.WithEnvPrefix("cmd"), simpleviper.WithEnvTransform(func(key, raw string) (any, error) {
+		if key != "secret" {
+			return raw, nil
+		}
+
+		b, err := base64.StdEncoding.DecodeString(raw)
+		if err != nil {
+			return nil, err
+		}
+
+		return string(b), nil
+	})).Init(fs)
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	fmt.Println(secret)
+	// Output: decoded from env var
+}
+
+// This example demonstrates looking up values without using the underlying viper instance.
+func ExampleViperlet_GetString() {
+	v := simpleviper.New(simpleviper.WithConfig("testdata/prefix.yml"))
+	if err := v.Init(); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	fmt.Println(v.IsSet("db.timeout"))
+	fmt.Println(v.GetString("db.timeout"))
+	fmt.Println(v.GetDuration("cache.timeout"))
+	fmt.Println(v.IsSet("missing"))
+	// Output:
+	// true
+	// 10s
+	// 1m0s
+	// false
+}
+
+// This example demonstrates looking up values with an inline default for keys that are not set.
+func ExampleViperlet_GetStringOr() {
+	v := simpleviper.New(simpleviper.WithConfig("testdata/prefix.yml"))
+	if err := v.Init(); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	fmt.Println(v.GetStringOr("db.timeout", "5s"))
+	fmt.Println(v.GetStringOr("missing", "fallback"))
+	fmt.Println(v.GetIntOr("missing", 10))
+	fmt.Println(v.GetBoolOr("missing", true))
+	// Output:
+	// 10s
+	// fallback
+	// 10
+	// true
+}
+
+// This example demonstrates requiring keys to be set from any source.
+func ExampleWithRequired() {
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.String("example1", "", "Example flag 1")
+	fs.String("example2", "", "Example flag 2")
+	fs.String("example4", "", "Example flag 4")
+	fs.Parse([]string{})
+
+	if err := simpleviper.New(simpleviper.WithConfig("example.yml"), simpleviper.WithRequired("example1", "example2", "example4")).Init(fs); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	// this is not executed
+	fmt.Println("success")
+	// Output: error: missing required keys: example1, example2
+}
+
+// This example demonstrates environment variables taking precedence over flags set on the command line.
+func ExampleWithEnvOverride() {
+	var example1, example2 string
+
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringVar(&example1, "example1", "", "Example flag 1")
+	fs.StringVar(&example2, "example2", "", "Example flag 2")
+	fs.Parse([]string{
+		"--example1", "overridden by env var",
+		"--example2", "from command line",
+	})
+
+	// set some env vars
+	os.Setenv("EXAMPLE1", "from env var")
+	defer os.Unsetenv("EXAMPLE1")
+
+	if err := simpleviper.New(simpleviper.WithEnv(), simpleviper.WithEnvOverride()).Init(fs); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	fmt.Println(example1)
+	fmt.Println(example2)
+	// Output:
+	// from env var
+	// from command line
+}
+
+// This example demonstrates a legacy config key populating a renamed flag.
+func ExampleWithAlias() {
+	var address string
+
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringVar(&address, "address", "", "Listen address")
+	fs.Parse([]string{})
+
+	if err := simpleviper.New(simpleviper.WithConfig("testdata/alias.yml"), simpleviper.WithAlias("listen_addr", "address")).Init(fs); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	fmt.Println(address)
+	// Output: from legacy config key
+}
+
+// This example demonstrates deriving defaults and required keys from the tags of a struct.
+func ExampleOptionsFromStruct() {
+	type Config struct {
+		Server struct {
+			Host    string        `mapstructure:"host" default:"localhost"`
+			Timeout time.Duration `mapstructure:"timeout" default:"30s"`
+		} `mapstructure:"server"`
+		Token string `mapstructure:"token" required:"true"`
+	}
+
+	opts, err := simpleviper.OptionsFromStruct(Config{})
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		return
+	}
+
+	// set some env vars
+	os.Setenv("TOKEN", "from env var")
+	defer os.Unsetenv("TOKEN")
+
+	v := simpleviper.New(append(opts, simpleviper.WithEnvVars("token"))...)
+	if err := v.Init(); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	var config Config
+	if err := v.Unmarshal(&config); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		return
+	}
+
+	fmt.Println(config.Server.Host)
+	fmt.Println(config.Server.Timeout)
+	fmt.Println(config.Token)
+	// Output:
+	// localhost
+	// 30s
+	// from env var
+}
+
+// This example demonstrates finding which config file was used after searching multiple paths.
+func ExampleViperlet_ConfigFileUsed() {
+	v := simpleviper.New(simpleviper.WithOptionalConfigName("search"), simpleviper.WithConfigPaths("testdata/missing", "testdata"))
+	if err := v.Init(); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	fmt.Println(filepath.Base(v.ConfigFileUsed()))
+
+	// a missing optional config file was not used
+	v = simpleviper.New(simpleviper.WithOptionalConfig("testdata/missing.yml"))
+	if err := v.Init(); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		return
+	}
+
+	fmt.Printf("%q\n", v.ConfigFileUsed())
+	// Output:
+	// search.yml
+	// ""
+}
+
+// This example demonstrates locating the config file using an env var.
+func ExampleWithConfigFileFromEnv() {
+	var example4 string
+
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringVar(&example4, "example4", "", "Example flag 4")
+	fs.Parse([]string{})
+
+	// set the config file location
+	os.Setenv("MYAPP_CONFIG", "example.yml")
+	defer os.Unsetenv("MYAPP_CONFIG")
+
+	v := simpleviper.New(simpleviper.WithConfigFileFromEnv("MYAPP_CONFIG"))
+	if err := v.Init(fs); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	fmt.Println(example4)
+	// Output:
+	// from config file
+}
+
+// This example demonstrates previewing the values that would be applied to flags without modifying them.
+func ExampleViperlet_DryRun() {
+	var example1, example4 string
+
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringVar(&example1, "example1", "", "Example flag 1")
+	fs.StringVar(&example4, "example4", "", "Example flag 4")
+	fs.StringSlice("hosts", nil, "Example slice flag")
+	fs.Parse([]string{"--example1", "from command line"})
+
+	values, err := simpleviper.New(simpleviper.WithMergeConfig("example.yml", "testdata/slice.yml")).DryRun(fs)
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		return
+	}
+
+	fmt.Println(values["example4"])
+	fmt.Println(values["hosts"])
+	fmt.Printf("%q\n", example4)
+	// Output:
+	// from config file
+	// a.example.com,b.example.com,c.example.com
+	// ""
+}
+
+// This example demonstrates strict decoding catching a misspelled key in a config file.
+func ExampleWithStrictUnmarshal() {
+	var config struct {
+		Example string `mapstructure:"example"`
+	}
+
+	v := simpleviper.New(simpleviper.WithConfig("testdata/typo.yml"), simpleviper.WithStrictUnmarshal())
+	if err := v.Init(); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	if err := v.Unmarshal(&config); err != nil {
+		fmt.Println("error: config file has unknown keys")
+
+		return
+	}
+
+	// this is not executed
+	fmt.Println(config.Example)
+	// Output: error: config file has unknown keys
+}
+
+// This example demonstrates binding individual keys to environment variables.
+func ExampleViperlet_BindEnv() {
+	var dbURL, token string
+
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringVar(&dbURL, "db-url", "", "Database URL")
+	fs.StringVar(&token, "token", "", "Token")
+	fs.Parse([]string{})
+
+	// set some env vars
+	os.Setenv("DATABASE_URL", "from env var without prefix")
+	os.Setenv("CMD_TOKEN", "from env var with prefix")
+	defer os.Unsetenv("DATABASE_URL")
+	defer os.Unsetenv("CMD_TOKEN")
+
+	v := simpleviper.New(simpleviper.WithEnvPrefix("cmd"), simpleviper.WithEnvVars())
+	if err := v.BindEnv("db-url", "DATABASE_URL"); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		return
+	}
+
+	if err := v.BindEnv("token"); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		return
+	}
+
+	if err := v.Init(fs); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	fmt.Println(dbURL)
+	fmt.Println(token)
+	// Output:
+	// from env var without prefix
+	// from env var with prefix
+}
+
+// This example demonstrates merging env vars into the config so they are included when unmarshalling.
+func ExampleWithEnvAsConfig() {
+	var example4 string
+
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringVar(&example4, "example4", "", "Example flag 4")
+	fs.Parse([]string{})
+
+	// set some env vars
+	os.Setenv("MYAPP_EXAMPLE3", "from env var")
+	os.Setenv("MYAPP_EXTRA", "from env var without a flag")
+	defer os.Unsetenv("MYAPP_EXAMPLE3")
+	defer os.Unsetenv("MYAPP_EXTRA")
+
+	v := simpleviper.New(simpleviper.WithConfig("example.yml"), simpleviper.WithEnvAsConfig("myapp"))
+	if err := v.Init(fs); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	var config struct {
+		Example3 string
+		Example4 string
+		Extra    string
+	}
+	if err := v.Unmarshal(&config); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		return
+	}
+
+	fmt.Println(config.Example3)
+	fmt.Println(config.Example4)
+	fmt.Println(config.Extra)
+	// Output:
+	// from env var
+	// from config file
+	// from env var without a flag
+}
+
+// This example demonstrates reloading config on demand, which in a real program would be done when a SIGHUP is received.
+func ExampleViperlet_Reload() {
+	var example1, example2 string
+
+	// create a config file to reload
+	dir, err := os.MkdirTemp("", "example")
+	if err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		return
+	}
+	defer os.RemoveAll(dir)
+
+	config := filepath.Join(dir, "config.yml")
+	if err := os.WriteFile(config, []byte("example1: from config file\nexample2: from config file\n"), 0o600); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		return
+	}
+
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringVar(&example1, "example1", "", "Example flag 1")
+	fs.StringVar(&example2, "example2", "", "Example flag 2")
+	fs.Parse([]string{"--example2", "from command line"})
+
+	v := simpleviper.New(simpleviper.WithConfig(config))
+	if err := v.Init(fs); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	fmt.Println(example1)
+
+	// update the config file and reload
+	if err := os.WriteFile(config, []byte("example1: from updated config file\nexample2: from updated config file\n"), 0o600); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		return
+	}
+
+	if err := v.Reload(); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		return
+	}
+
+	fmt.Println(example1)
+	fmt.Println(example2)
+	// Output:
+	// from config file
+	// from updated config file
+	// from command line
+}
+
+// This example demonstrates decrypting values from a config file, which in this case are only base64 encoded.
+func ExampleWithValueDecryptor() {
+	var username, password string
+
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringVar(&username, "username", "", "Username")
+	fs.StringVar(&password, "password", "", "Password")
+	fs.Parse([]string{})
+
+	decrypt := func(key string, raw []byte) ([]byte, error) {
+		// only values wrapped in ENC[...] are encrypted
+		encrypted, ok := bytes.CutPrefix(raw, []byte("ENC["))
+		if !ok || !bytes.HasSuffix(encrypted, []byte("]")) {
+			return raw, nil
+		}
+
+		return base64.StdEncoding.DecodeString(string(bytes.TrimSuffix(encrypted, []byte("]"))))
+	}
+
+	v := simpleviper.New(simpleviper.WithConfig("testdata/secret.yml"), simpleviper.WithValueDecryptor(decrypt))
+	if err := v.Init(fs); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	fmt.Println(username)
+	fmt.Println(password)
+	// Output:
+	// admin
+	// hunter2
+}
+
+// This example demonstrates copying a configured Viperlet and changing one option.
+func ExampleViperlet_Clone() {
+	base := simpleviper.New(simpleviper.WithDefaults(map[string]any{"example": "default value"}), simpleviper.WithConfig("testdata/base.yml"))
+
+	for _, v := range []*simpleviper.Viperlet{base, base.Clone(simpleviper.WithConfig("testdata/search.yml"))} {
+		// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+		fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+		example := fs.String("example", "", "Example flag")
+		fs.Parse([]string{})
+
+		if err := v.Init(fs); err != nil {
+			fmt.Printf("error: %s\n", err)
+
+			// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+			return
+		}
+
+		fmt.Println(*example)
+	}
+	// Output:
+	// default value
+	// from config file found in search paths
+}
+
+//go:embed testdata/base.yml
+var embeddedConfig embed.FS
+
+// This example demonstrates reading config embedded in the binary.
+func ExampleWithConfigFS() {
+	var example1 string
+
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringVar(&example1, "example1", "", "Example flag 1")
+	fs.Parse([]string{})
+
+	v := simpleviper.New(simpleviper.WithConfigFS(embeddedConfig, "testdata/base.yml"))
+	if err := v.Init(fs); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	fmt.Println(example1)
+	// Output:
+	// from base config file
+}
+
+// This example demonstrates binding a flag to an env var with a name that does not match the flag name.
+func ExampleWithFlagEnv() {
+	var dbURL string
+
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringVar(&dbURL, "db-url", "", "Database URL")
+	fs.Parse([]string{})
+
+	// set env var
+	os.Setenv("DATABASE_URL", "from env var")
+	defer os.Unsetenv("DATABASE_URL")
+
+	v := simpleviper.New(simpleviper.WithFlagEnv("db-url", "DATABASE_URL"))
+	if err := v.Init(fs); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	fmt.Println(dbURL)
+	// Output:
+	// from env var
+}
+
+// This example demonstrates rolling back changes to settings using a snapshot.
+func ExampleViperlet_Snapshot() {
+	v := simpleviper.New(simpleviper.WithConfig("testdata/slice.yml"))
+	if err := v.Init(); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	snapshot := v.Snapshot()
+
+	// change some settings
+	v.Viper().Set("hosts", []string{"changed.example.com"})
+	v.Viper().Set("added", "added value")
+	fmt.Println(v.GetStringSlice("hosts"), v.IsSet("added"))
+
+	v.Restore(snapshot)
+	fmt.Println(v.GetStringSlice("hosts"), v.IsSet("added"))
+	// Output:
+	// [changed.example.com] true
+	// [a.example.com b.example.com,c.example.com] false
+}
+
+// This example demonstrates setting a default after the Viperlet is created.
+func ExampleViperlet_SetDefault() {
+	var example string
+
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringVar(&example, "example", "", "Example flag")
+	fs.Parse([]string{})
+
+	// the zero value is usable
+	var v simpleviper.Viperlet
+	v.SetDefault("example", "default value")
+
+	if err := v.Init(fs); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	fmt.Println(example)
+	// Output:
+	// default value
+}
+
+// This example demonstrates auditing the values applied to flags.
+func ExampleWithFlagApplyHook() {
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.String("example3", "", "Example flag 3")
+	fs.String("example4", "", "Example flag 4")
+	fs.String("example6", "", "Example flag 6")
+	fs.String("example7", "default value", "Example flag 7")
+	fs.Parse([]string{"--example6", "from command line"})
+
+	// set env var
+	os.Setenv("EXAMPLE3", "from env var")
+	defer os.Unsetenv("EXAMPLE3")
+
+	audit := func(f *pflag.Flag, value string, source string) error {
+		fmt.Printf("%s=%q from %s\n", f.Name, value, source)
+
+		return nil
+	}
+
+	v := simpleviper.New(simpleviper.WithEnv(), simpleviper.WithConfig("example.yml"), simpleviper.WithFlagApplyHook(audit))
+	if err := v.Init(fs); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+	// Output:
+	// example3="from env var" from env
+	// example4="from config file" from config
+	// example6="from command line" from flag
+	// example7="default value" from default
+}
+
+// This example demonstrates resolving values without applying them to flags.
+func ExampleWithoutFlagPropagation() {
+	var example4 string
+
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringVar(&example4, "example4", "flag default", "Example flag 4")
+	fs.Parse([]string{})
+
+	v := simpleviper.New(simpleviper.WithConfig("example.yml"), simpleviper.WithoutFlagPropagation())
+	if err := v.Init(fs); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	fmt.Println(example4)
+	fmt.Println(v.GetString("example4"))
+	fmt.Println(fs.Changed("example4"))
+	// Output:
+	// flag default
+	// from config file
+	// false
+}
+
+// This example demonstrates initialising without handling errors in a simple program.
+func ExampleViperlet_MustInit() {
+	var example4 string
+
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringVar(&example4, "example4", "", "Example flag 4")
+	fs.Parse([]string{})
+
+	// this panics on error
+	simpleviper.New(simpleviper.WithConfig("example.yml")).MustInit(fs)
+
+	fmt.Println(example4)
+	// Output:
+	// from config file
+}
+
+// This example demonstrates expanding references to env vars in config values.
+func ExampleWithEnvExpansion() {
+	var url string
+
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringVar(&url, "url", "", "URL")
+	fs.Parse([]string{})
+
+	// set env vars
+	os.Setenv("EXAMPLE_HOST", "example.com")
+	os.Setenv("EXAMPLE_PORT", "8443")
+	defer os.Unsetenv("EXAMPLE_HOST")
+	defer os.Unsetenv("EXAMPLE_PORT")
+
+	v := simpleviper.New(simpleviper.WithConfig("testdata/expand.yml"), simpleviper.WithEnvExpansion())
+	if err := v.Init(fs); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	fmt.Println(url)
+	// Output:
+	// https://example.com:8443/
+}
+
+// This example demonstrates listing all known keys.
+func ExampleViperlet_AllKeys() {
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.String("example1", "", "Example flag 1")
+	fs.Parse([]string{})
+
+	v := simpleviper.New(simpleviper.WithConfig("testdata/base.yml"))
+	fmt.Println(len(v.AllKeys()))
+
+	if err := v.Init(fs); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	keys := v.AllKeys()
+	slices.Sort(keys)
+	fmt.Println(keys)
+	// Output:
+	// 0
+	// [example1 example2]
+}
+
+func ExampleWithProfile() {
+	var listen, profile string
+	var debug bool
+
+	// create flagset, which in a real program (not an example) would use pflag.ExitOnError
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringVar(&listen, "listen", "", "Listen address")
+	fs.BoolVar(&debug, "debug", false, "Enable debug logging")
+	fs.StringVar(&profile, "profile", "", "Config profile")
+	fs.Parse([]string{"--profile", "dev"})
+
+	v := simpleviper.New(simpleviper.WithConfig("testdata/profiles.yml"), simpleviper.WithProfile("profile"))
+	if err := v.Init(fs); err != nil {
+		fmt.Printf("error: %s\n", err)
+
+		// a real program (not an example) would exit with a non-zero exit code at this point, but in this case we return
+		return
+	}
+
+	fmt.Println(listen)
+	fmt.Println(debug)
+	// Output:
+	// localhost:8080
+	// true
+}
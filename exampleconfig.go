@@ -0,0 +1,228 @@
+package simpleviper
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cast"
+	"github.com/spf13/pflag"
+)
+
+// Errors returned by ExampleConfig
+var (
+	// ErrUnsupportedFormat is returned when an example config cannot be generated in the requested format
+	ErrUnsupportedFormat = errors.New("unsupported config format")
+)
+
+// exampleEntry is the value and description of a key in an example config
+type exampleEntry struct {
+	value any
+	usage string
+}
+
+// ExampleConfig returns a config file skeleton in the provided format containing every key from the bound flags, with the default of the
+// flag as the value and its usage string as a comment, along with any keys provided using [WithDefaults], which is useful to help users get
+// started. Keys are sorted and nested keys are written as nested sections, except for [FormatENV] where the key is the uppercased full key.
+//
+// The flags are those from the last call to Init, or those provided using [WithFlagSet] if Init has not been called, while flags provided
+// using [WithIgnoredFlags] are skipped. As JSON does not support comments, usage strings are not included for [FormatJSON]. An error wrapping
+// [ErrUnsupportedFormat] is returned for [FormatHCL] or [FormatUnknown].
+func (v *Viperlet) ExampleConfig(format ConfigFormat) ([]byte, error) {
+	flagset := v.initFlagsets
+	if flagset == nil {
+		flagset = v.flagsets
+	}
+
+	entries := make(map[string]exampleEntry)
+	for _, fs := range flagset {
+		fs.VisitAll(func(f *pflag.Flag) {
+			if !v.ignored(f) {
+				entries[strings.ToLower(v.flagKey(f.Name))] = exampleEntry{value: exampleValue(f), usage: f.Usage}
+			}
+		})
+	}
+
+	// defaults take precedence over the default value of a flag
+	for key, value := range v.defaults {
+		key = strings.ToLower(key)
+		entries[key] = exampleEntry{value: value, usage: entries[key].usage}
+	}
+
+	var buf bytes.Buffer
+	switch format {
+	case FormatYAML:
+		writeExampleYAML(&buf, exampleTree(entries, v.keyDelim()), "")
+	case FormatTOML:
+		writeExampleTOML(&buf, exampleTree(entries, v.keyDelim()), "")
+	case FormatJSON:
+		values := make(map[string]any)
+		for key, entry := range entries {
+			setNested(values, strings.Split(key, v.keyDelim()), entry.value)
+		}
+
+		out, err := json.MarshalIndent(values, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+
+		buf.Write(out)
+		buf.WriteString("\n")
+	case FormatENV:
+		for _, key := range slices.Sorted(maps.Keys(entries)) {
+			writeExampleComment(&buf, "", entries[key].usage)
+			fmt.Fprintf(&buf, "%s=%s\n", strings.ToUpper(key), strconv.Quote(exampleEnvValue(entries[key].value)))
+		}
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedFormat, format.String())
+	}
+
+	return buf.Bytes(), nil
+}
+
+// exampleValue returns the default value of the flag with a type matching the type of the flag where possible
+func exampleValue(f *pflag.Flag) any {
+	if _, ok := f.Value.(pflag.SliceValue); ok {
+		raw := strings.TrimSuffix(strings.TrimPrefix(f.DefValue, "["), "]")
+		if raw == "" {
+			return []string{}
+		}
+
+		values, err := csv.NewReader(strings.NewReader(raw)).Read()
+		if err != nil {
+			return []string{raw}
+		}
+
+		return values
+	}
+
+	switch f.Value.Type() {
+	case "bool":
+		if b, err := cast.ToBoolE(f.DefValue); err == nil {
+			return b
+		}
+	case "int", "int8", "int16", "int32", "int64", "uint", "uint8", "uint16", "uint32", "uint64", "count":
+		if n, err := cast.ToInt64E(f.DefValue); err == nil {
+			return n
+		}
+	case "float32", "float64":
+		if n, err := cast.ToFloat64E(f.DefValue); err == nil {
+			return n
+		}
+	}
+
+	return f.DefValue
+}
+
+// exampleTree returns the entries as a nested map, where each leaf is an exampleEntry, so nested keys can be written as sections. When a key
+// is both a value and a section, the value wins.
+func exampleTree(entries map[string]exampleEntry, delim string) map[string]any {
+	tree := make(map[string]any)
+	for _, key := range slices.Sorted(maps.Keys(entries)) {
+		node := tree
+		path := strings.Split(key, delim)
+		for _, name := range path[:len(path)-1] {
+			child, ok := node[name].(map[string]any)
+			if !ok {
+				if _, isEntry := node[name].(exampleEntry); isEntry {
+					node = nil
+					break
+				}
+
+				child = make(map[string]any)
+				node[name] = child
+			}
+			node = child
+		}
+
+		if node != nil {
+			if _, isSection := node[path[len(path)-1]].(map[string]any); !isSection {
+				node[path[len(path)-1]] = entries[key]
+			}
+		}
+	}
+
+	return tree
+}
+
+// writeExampleYAML writes the tree as YAML, with nested sections indented
+func writeExampleYAML(buf *bytes.Buffer, tree map[string]any, indent string) {
+	for _, name := range slices.Sorted(maps.Keys(tree)) {
+		switch node := tree[name].(type) {
+		case exampleEntry:
+			writeExampleComment(buf, indent, node.usage)
+			fmt.Fprintf(buf, "%s%s: %s\n", indent, name, exampleScalar(node.value))
+		case map[string]any:
+			fmt.Fprintf(buf, "%s%s:\n", indent, name)
+			writeExampleYAML(buf, node, indent+"  ")
+		}
+	}
+}
+
+// writeExampleTOML writes the tree as TOML, with the values of each section written before any nested tables
+func writeExampleTOML(buf *bytes.Buffer, tree map[string]any, table string) {
+	names := slices.Sorted(maps.Keys(tree))
+	for _, name := range names {
+		if node, ok := tree[name].(exampleEntry); ok {
+			writeExampleComment(buf, "", node.usage)
+			fmt.Fprintf(buf, "%s = %s\n", name, exampleScalar(node.value))
+		}
+	}
+
+	for _, name := range names {
+		if node, ok := tree[name].(map[string]any); ok {
+			path := name
+			if table != "" {
+				path = table + "." + name
+			}
+
+			fmt.Fprintf(buf, "\n[%s]\n", path)
+			writeExampleTOML(buf, node, path)
+		}
+	}
+}
+
+// writeExampleComment writes the usage as a comment, with one comment line per line of the usage
+func writeExampleComment(buf *bytes.Buffer, indent, usage string) {
+	if usage == "" {
+		return
+	}
+
+	for line := range strings.SplitSeq(usage, "\n") {
+		fmt.Fprintf(buf, "%s# %s\n", indent, line)
+	}
+}
+
+// exampleScalar formats the value so it is valid in both YAML and TOML
+func exampleScalar(value any) string {
+	switch value := value.(type) {
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, float32, float64:
+		return fmt.Sprint(value)
+	case []string, []any:
+		values := cast.ToStringSlice(value)
+		quoted := make([]string, len(values))
+		for i, s := range values {
+			quoted[i] = strconv.Quote(s)
+		}
+
+		return "[" + strings.Join(quoted, ", ") + "]"
+	}
+
+	return strconv.Quote(cast.ToString(value))
+}
+
+// exampleEnvValue formats the value for an env file, where lists are joined with a ","
+func exampleEnvValue(value any) string {
+	switch value.(type) {
+	case []string, []any:
+		return strings.Join(cast.ToStringSlice(value), ",")
+	}
+
+	return cast.ToString(value)
+}
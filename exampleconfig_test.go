@@ -0,0 +1,92 @@
+package simpleviper
+
+import (
+	"bytes"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/spf13/pflag"
+)
+
+func TestExampleConfig(t *testing.T) {
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.String("name", "example", "Name of the service")
+	fs.Int("port", 8080, "Port to listen on")
+	fs.Bool("debug", false, "Enable debug logging")
+	fs.Duration("db.timeout", 30*time.Second, "Database timeout")
+	fs.String("db.host", "localhost", "Database host\nwhich may be a hostname or IP")
+	fs.StringSlice("hosts", []string{"a.example.com", "b.example.com"}, "Hosts")
+	fs.String("help", "", "Ignored flag")
+	fs.Parse([]string{})
+
+	v := New(WithIgnoredFlags("help"), WithDefaults(map[string]any{"db.user": "admin", "port": 9090}))
+	if err := v.Init(fs); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	tests := []struct {
+		name         string
+		format       ConfigFormat
+		wantComments bool
+	}{
+		{"yaml", FormatYAML, true},
+		{"toml", FormatTOML, true},
+		{"json", FormatJSON, false},
+		{"env", FormatENV, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, err := v.ExampleConfig(tt.format)
+			if err != nil {
+				t.Fatalf("ExampleConfig() error = %v", err)
+			}
+
+			if got := strings.Contains(string(out), "# Database host\n"); got != tt.wantComments {
+				t.Errorf("ExampleConfig() contains comment = %v, want %v\n%s", got, tt.wantComments, out)
+			}
+
+			if strings.Contains(string(out), "help") {
+				t.Errorf("ExampleConfig() contains ignored flag\n%s", out)
+			}
+
+			// the generated config can be read back
+			r := New(WithConfigReader(bytes.NewReader(out), tt.format.String()))
+			if err := r.Init(); err != nil {
+				t.Fatalf("Init() error = %v\n%s", err, out)
+			}
+
+			want := map[string]string{
+				"name":       "example",
+				"port":       "9090",
+				"debug":      "false",
+				"db.timeout": "30s",
+				"db.host":    "localhost",
+				"db.user":    "admin",
+			}
+			for key, value := range want {
+				if got := r.GetString(key); got != value {
+					t.Errorf("GetString(%q) = %q, want %q\n%s", key, got, value, out)
+				}
+			}
+
+			hosts := []string{"a.example.com", "b.example.com"}
+			if tt.format == FormatENV {
+				hosts = []string{"a.example.com,b.example.com"}
+			}
+
+			if got := r.GetStringSlice("hosts"); !slices.Equal(got, hosts) {
+				t.Errorf("GetStringSlice() = %q, want %q\n%s", got, hosts, out)
+			}
+		})
+	}
+
+	for _, format := range []ConfigFormat{FormatHCL, FormatUnknown} {
+		if _, err := v.ExampleConfig(format); !errors.Is(err, ErrUnsupportedFormat) {
+			t.Errorf("ExampleConfig(%v) error = %v, want %v", format, err, ErrUnsupportedFormat)
+		}
+	}
+}
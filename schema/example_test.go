@@ -0,0 +1,48 @@
+package schema_test
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/andrewheberle/simpleviper"
+	"github.com/andrewheberle/simpleviper/schema"
+)
+
+const configSchema = `{
+	"type": "object",
+	"properties": {
+		"port": {"type": "integer", "minimum": 1, "maximum": 65535}
+	},
+	"required": ["port"]
+}`
+
+// This example demonstrates validating config against a JSON Schema.
+func ExampleWithJSONSchema() {
+	for _, config := range []string{"port: 8080\n", "port: 80000\n"} {
+		v := simpleviper.New(
+			simpleviper.WithConfigReader(strings.NewReader(config), "yaml"),
+			schema.WithJSONSchema([]byte(configSchema)),
+		)
+		if err := v.Init(); err != nil {
+			fmt.Printf("invalid config: %t\n", errors.Is(err, schema.ErrInvalidConfig))
+
+			continue
+		}
+
+		fmt.Println(v.GetInt("port"))
+	}
+	// Output:
+	// 8080
+	// invalid config: true
+}
+
+// This example demonstrates the error returned when the schema is not valid.
+func ExampleWithJSONSchema_invalidSchema() {
+	v := simpleviper.New(schema.WithJSONSchema([]byte(`{"type": "unknown"}`)))
+	if err := v.Init(); err != nil {
+		fmt.Printf("invalid schema: %t\n", errors.Is(err, schema.ErrInvalidSchema))
+	}
+	// Output:
+	// invalid schema: true
+}
@@ -0,0 +1,84 @@
+// Package schema provides validation of the config loaded by a [simpleviper.Viperlet] against a JSON Schema.
+//
+// This is a separate package so the JSON Schema library is only a dependency of programs that use it.
+package schema
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"github.com/andrewheberle/simpleviper"
+	"github.com/santhosh-tekuri/jsonschema/v6"
+	"github.com/spf13/viper"
+)
+
+// Errors returned by the validator
+var (
+	// ErrInvalidSchema is returned when the schema cannot be parsed or compiled
+	ErrInvalidSchema = errors.New("invalid schema")
+
+	// ErrInvalidConfig is returned when the config does not match the schema
+	ErrInvalidConfig = errors.New("config does not match schema")
+)
+
+// schemaURL is the location the schema is added to the compiler as, which is only used in error messages
+const schemaURL = "config.schema.json"
+
+// WithJSONSchema validates the config against the provided JSON Schema once all values are merged, using [simpleviper.WithValidate], so
+// structural problems in the config are caught before the program starts. The config is the result of [viper.Viper.AllSettings] serialised
+// as JSON, so keys are lowercased and values from env vars are strings, which should be allowed by the schema for any key that can be set
+// using an env var.
+//
+// Init returns an error wrapping [ErrInvalidConfig] describing each problem if the config does not match the schema, or an error wrapping
+// [ErrInvalidSchema] if the schema is not valid.
+func WithJSONSchema(schema []byte) simpleviper.Option {
+	return simpleviper.WithValidate(func(v *viper.Viper) error {
+		sch, err := compile(schema)
+		if err != nil {
+			return err
+		}
+
+		return validate(sch, v.AllSettings())
+	})
+}
+
+// compile parses and compiles the schema
+func compile(schema []byte) (*jsonschema.Schema, error) {
+	doc, err := jsonschema.UnmarshalJSON(bytes.NewReader(schema))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSchema, err)
+	}
+
+	c := jsonschema.NewCompiler()
+	if err := c.AddResource(schemaURL, doc); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSchema, err)
+	}
+
+	sch, err := c.Compile(schemaURL)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidSchema, err)
+	}
+
+	return sch, nil
+}
+
+// validate validates the settings against the schema, round-tripping the settings through JSON so values have the types the schema expects
+func validate(sch *jsonschema.Schema, settings map[string]any) error {
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+	}
+
+	inst, err := jsonschema.UnmarshalJSON(bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+	}
+
+	if err := sch.Validate(inst); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidConfig, err)
+	}
+
+	return nil
+}
@@ -6,31 +6,303 @@
 package simpleviper
 
 import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"encoding/csv"
 	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"maps"
+	"net/http"
+	"net/url"
 	"os"
+	"path"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/cast"
 	"github.com/spf13/pflag"
 	"github.com/spf13/viper"
+	"github.com/subosito/gotenv"
 )
 
 // Errors returned by Init
 var (
+	// ErrInvalidFlagset is returned when a flagset passed to Init or provided using WithFlagSet is nil or cannot be bound
 	ErrInvalidFlagset = errors.New("invalid flagset")
+
+	// ErrReadConfig is returned when a config file cannot be read
+	ErrReadConfig = errors.New("reading config")
+
+	// ErrConfigParse is returned, along with ErrReadConfig, when a config file was found but could not be parsed
+	ErrConfigParse = errors.New("parsing config")
+
+	// ErrNothingToBind is returned when WithStrict is used and there is nothing to bind
+	ErrNothingToBind = errors.New("nothing to bind")
+
+	// ErrMissingRequired is returned when keys provided using WithRequired are not set
+	ErrMissingRequired = errors.New("missing required keys")
+
+	// ErrBindEnv is returned when a key cannot be bound to env vars
+	ErrBindEnv = errors.New("binding env var")
+
+	// ErrConfigTooLarge is returned when a config file is larger than the size set using WithMaxConfigSize
+	ErrConfigTooLarge = errors.New("config too large")
+
+	// ErrUndefinedEnvVar is returned when WithStrictEnvExpansion is used and a config value references an env var that is not set
+	ErrUndefinedEnvVar = errors.New("undefined env var")
+
+	// ErrConflictingOptions is returned when options that cannot be used together were passed to New
+	ErrConflictingOptions = errors.New("conflicting options")
+
+	// ErrEmptyConfig is returned, along with ErrReadConfig, when WithNonEmptyConfig is used and a config file has no values
+	ErrEmptyConfig = errors.New("empty config")
+
+	// ErrUnknownProfile is returned when the profile selected using WithProfile is not in the config
+	ErrUnknownProfile = errors.New("unknown profile")
+
+	// ErrInvalidEnvFilter is returned when the pattern provided using WithEnvFilter is not a valid regular expression
+	ErrInvalidEnvFilter = errors.New("invalid env filter")
+)
+
+// Errors returned by Get
+var (
+	// ErrTypeMismatch is returned when a value cannot be converted to the requested type
+	ErrTypeMismatch = errors.New("type mismatch")
+
+	// ErrUnsupportedType is returned when the requested type is not supported
+	ErrUnsupportedType = errors.New("unsupported type")
+)
+
+// A Source is where a value came from, as reported by [Viperlet.InitWithReport] and passed to the hook provided using [WithFlagApplyHook].
+type Source string
+
+// Sources of values
+const (
+	SourceFlag    Source = "flag"
+	SourceEnv     Source = "env"
+	SourceConfig  Source = "config"
+	SourceDefault Source = "default"
+
+	// SourceOverride is a value provided using WithOverrides
+	SourceOverride Source = "override"
+)
+
+// A ReportEntry is the resolved value of a key and where it came from.
+type ReportEntry struct {
+	Value  any
+	Source Source
+}
+
+// A Report maps each key to its resolved value and source, as returned by [Viperlet.InitWithReport].
+type Report map[string]ReportEntry
+
+// conflictingOptions are pairs of sets of options where using options from both sets is ambiguous, as the result depends on the order the
+// options are applied
+var conflictingOptions = [][2][]string{
+	{
+		{"WithConfig", "WithConfigName", "WithConfigNameAnyFormat", "WithMergeConfig", "WithConfigFS", "WithConfigURL"},
+		{"WithOptionalConfig", "WithOptionalConfigName", "WithOptionalConfigNameAnyFormat", "WithOptionalMergeConfig", "WithOptionalConfigFS", "WithOptionalConfigURL"},
+	},
+	{{"WithConfigReader"}, {"WithConfigFS", "WithOptionalConfigFS", "WithConfigURL", "WithOptionalConfigURL"}},
+	{{"WithConfigFS", "WithOptionalConfigFS"}, {"WithConfigURL", "WithOptionalConfigURL"}},
+	{{"WithConfigReader", "WithConfigFS", "WithOptionalConfigFS", "WithConfigURL", "WithOptionalConfigURL"}, {"WithConfigFromEncodedEnv"}},
+	{{"WithEnvPrefix"}, {"WithEnvPrefixes"}},
+	{{"WithEnvKeyReplacer"}, {"WithEnvKeyReplacerDefault"}},
+}
+
+// A ConfigFormat is a supported config file format, which can be used with [WithConfigFormat] instead of passing the type as a string.
+type ConfigFormat int
+
+// Supported config file formats
+const (
+	// FormatUnknown leaves the format to be inferred from the config file extension
+	FormatUnknown ConfigFormat = iota
+	FormatYAML
+	FormatJSON
+	FormatTOML
+	FormatHCL
+	FormatENV
+)
+
+// String returns the config type as expected by [viper.SetConfigType], or an empty string for unknown formats.
+func (f ConfigFormat) String() string {
+	switch f {
+	case FormatYAML:
+		return "yaml"
+	case FormatJSON:
+		return "json"
+	case FormatTOML:
+		return "toml"
+	case FormatHCL:
+		return "hcl"
+	case FormatENV:
+		return "env"
+	}
+
+	return ""
+}
+
+// An Encoding is how the config in an env var provided using [WithConfigFromEncodedEnv] is encoded, where encodings may be combined, such as
+// EncodingBase64|EncodingGzip for config that was compressed using gzip and then base64 encoded.
+type Encoding int
+
+// Supported encodings
+const (
+	// EncodingNone is config that is not encoded
+	EncodingNone Encoding = 0
+
+	// EncodingBase64 is config that is base64 encoded using the standard encoding, as per [base64.StdEncoding]
+	EncodingBase64 Encoding = 1
+
+	// EncodingGzip is config that is compressed using gzip, which is decompressed after any base64 decoding
+	EncodingGzip Encoding = 2
+)
+
+// A ReloadMode controls how values are applied to flags when the config file changes while using [WithWatch], which is set using
+// [WithWatchReloadMode].
+type ReloadMode int
+
+// Supported reload modes
+const (
+	// ReloadNone only updates the underlying [*viper.Viper] instance, leaving flags untouched
+	ReloadNone ReloadMode = iota
+
+	// ReloadAll applies the values from the changed config to flags, as per [Viperlet.Reload]
+	ReloadAll
+
+	// ReloadChanged applies the values from the changed config to flags only for keys where the value in the config file has changed
+	ReloadChanged
 )
 
 // A Viperlet is used to bind flags with env vars based on the options provided to New.
 //
 // Although it is safe to use an unitialised Viperlet, it is equivalent to calling New without any options, so it's usefulness is limited.
+//
+// The Viper method is safe to call from multiple goroutines, as the underlying [*viper.Viper] instance is only ever created once, and calls
+// to Init, InitContext, InitWithReport, Rebind, Reload and Reset, along with reloading the config when it changes using [WithWatch], are
+// serialised so they never run at the same time. As the underlying instance is not safe for concurrent use, all other methods, including
+// getters such as GetString, must not be called while one of these is running. A Viperlet must not be copied once used, so use Clone instead.
 type Viperlet struct {
+	// mu guards the creation of the underlying viper instance, initMu serialises calls to Init, Rebind, Reload and Reset along with watched
+	// config changes, and watchMu guards the watcher
+	mu      sync.Mutex
+	initMu  sync.Mutex
+	watchMu sync.Mutex
+
 	viper *viper.Viper
 
-	// options
-	bindEnv            bool
-	envPrefix          string
-	envKeyReplacer     *strings.Replacer
-	configFile         string
-	allowMissingConfig bool
+	// configRead is true once a config file has been read successfully by Init
+	configRead bool
+
+	// initFlagsets are the flagsets from the last call to Init and applied are the flags that had a value applied, which are used by Reload
+	initFlagsets []*pflag.FlagSet
+	applied      map[*pflag.Flag]bool
+
+	// lazyLoaded is true once the config has been read when using WithLazyConfig, with lazyErr being the error from reading it, if any, where
+	// lazyMu guards both so the config is only read once when getters are called from multiple goroutines
+	lazyMu     sync.Mutex
+	lazyLoaded bool
+	lazyErr    error
+
+	// lastConfig are the values from the config file when it was last read, which are used to find changed keys when using ReloadChanged
+	lastConfig map[string]any
+
+	// watcher is watching the config file when using WithWatch, with watchDone being closed once the goroutine handling changes has exited
+	watcher   *fsnotify.Watcher
+	watchDone chan struct{}
+
+	// dotEnv are the values from the dotenv file when using WithEnvLookup, which are not loaded into the environment
+	dotEnv map[string]string
+
+	// envFilterRegexp is the compiled pattern provided using WithEnvFilter, which is compiled by Init
+	envFilterRegexp *regexp.Regexp
+
+	options
+}
+
+// options are the settings provided using an [Option], which are kept apart from the state of a [Viperlet] so they can be copied by Clone
+type options struct {
+	// optionsUsed are the names of options that were applied, which are used to detect conflicting options
+	optionsUsed map[string]bool
+
+	keyDelimiter          string
+	flagsets              []*pflag.FlagSet
+	flagKeyPrefix         string
+	ignoredFlags          []string
+	bindEnv               bool
+	envPrefix             string
+	envPrefixes           []string
+	envPrefixSeparator    string
+	envFilter             string
+	envLookup             func(string) (string, bool)
+	envKeyReplacer        *strings.Replacer
+	defaultEnvKeyReplacer bool
+	envVars               []string
+	envTransform          func(key, raw string) (any, error)
+	envOverride           bool
+	envCaseInsensitive    bool
+	flagEnvs              map[string][]string
+	envAsConfigPrefix     string
+	profileKey            string
+	lenientEnvBinding     bool
+	valueDecryptor        func(key string, raw []byte) ([]byte, error)
+	envExpansion          bool
+	strictEnvExpansion    bool
+	dotEnvFile            string
+	allowMissingDotEnv    bool
+	configFile            string
+	configFileEnv         string
+	configEncodedEnv      string
+	configEncodedType     string
+	configEncoding        Encoding
+	configExeName         string
+	configName            string
+	configAnyFormat       bool
+	configPaths           []string
+	configType            string
+	mergeConfigFiles      []string
+	configReader          io.Reader
+	configReaderType      string
+	configURL             string
+	configURLType         string
+	fallbackConfigFile    string
+	httpClient            *http.Client
+	remoteProviders       []remoteProvider
+	configFS              fs.FS
+	configFSName          string
+	allowMissingConfig    bool
+	maxConfigSize         int64
+	configTimeout         time.Duration
+	defaults              map[string]any
+	overrides             map[string]any
+	aliases               map[string]string
+	watchConfig           bool
+	watchReloadMode       ReloadMode
+	lazyConfig            bool
+	stringNormalizer      func(string) string
+	nonEmptyConfig        bool
+	unknownKeyHandler     func(key string)
+	required              []string
+	validators            []func(*viper.Viper) error
+	writeConfigFile       string
+	strict                bool
+	strictUnmarshal       bool
+	logger                *slog.Logger
+	flagApplyHook         func(f *pflag.Flag, value string, source string) error
+	noPropagation         bool
+	onConfigChange        func(fsnotify.Event)
 }
 
 // New returns an initialised [Viperlet] instance. The behaviour of the returned [*Viperlet] can be altered by passing various [Option]'s.
@@ -38,8 +310,22 @@ type Viperlet struct {
 // Creating a new Viperlet with no [Option]'s is valid but it does not provide any specific features without manually using the underlying
 // [*viper.Viper] instance via the [Viper] method.
 //
-// Please also keep in mind that passing incompatible or duplicated options, such as [WithConfig] and [WithOptionalConfig] together or
-// passing [WithEnvPrefix] multiple times will lead to unexpected results depending on the order the options are applied.
+// Options are applied in order, where options that set a single value, such as [WithEnvPrefix] or [WithConfig], replace the value set by
+// any earlier option that sets the same value, so the last one wins. Options that add to a list, such as [WithEnvVars], [WithConfigPaths],
+// [WithDefaults], [WithRequired], [WithValidate], [WithAlias] and [WithFlagSet], accumulate instead. Passing incompatible options, such as
+// [WithConfig] and [WithOptionalConfig] together, causes Init to return an error wrapping [ErrConflictingOptions] that names the options.
+//
+// Where different options provide the config, the precedence does not depend on the order the options are applied, and only the first of
+// the following that is set is used:
+//
+//  1. [WithConfigReader]
+//  2. [WithConfigFS] or [WithOptionalConfigFS]
+//  3. [WithConfigURL] or [WithOptionalConfigURL]
+//  4. [WithConfigFromEncodedEnv], when the env var is set
+//  5. [WithConfigFileFromEnv], when the env var is set
+//  6. [WithConfig], [WithOptionalConfig], [WithMergeConfig] or [WithOptionalMergeConfig]
+//  7. [WithConfigBesideExecutable]
+//  8. [WithConfigName], [WithOptionalConfigName], [WithConfigNameAnyFormat] or [WithOptionalConfigNameAnyFormat]
 func New(opts ...Option) *Viperlet {
 	v := new(Viperlet)
 
@@ -53,109 +339,3121 @@ func New(opts ...Option) *Viperlet {
 
 // Viper provides access to the underlying [*viper.Viper] instance
 func (v *Viperlet) Viper() *viper.Viper {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
 	if v.viper == nil {
-		v.viper = viper.New()
+		v.viper = v.newViper()
 	}
 
 	return v.viper
 }
 
+// setViper replaces the underlying [*viper.Viper] instance
+func (v *Viperlet) setViper(vp *viper.Viper) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	v.viper = vp
+}
+
+// BindFlag binds a single flag to the underlying [*viper.Viper] instance, using any prefix set with [WithFlagKeyPrefix], which is useful
+// for flags that are added dynamically. This is safe to call before or after Init, however as values are only applied to flags during Init
+// a flag bound afterwards will not have its value updated, but its value will be visible via the underlying [*viper.Viper] instance.
+// See [viper.BindPFlag] for details.
+func (v *Viperlet) BindFlag(f *pflag.Flag) error {
+	return v.Viper().BindPFlag(v.flagKey(f.Name), f)
+}
+
+// SetDefault sets the default value for a key on the underlying [*viper.Viper] instance, which complements [WithDefaults] when the default is
+// only known after calling [New]. This must be called before Init for the default to be applied to flags. Unlike those provided using
+// [WithDefaults], the default is discarded by Reset. See [viper.SetDefault] for details.
+func (v *Viperlet) SetDefault(key string, value any) {
+	v.Viper().SetDefault(key, value)
+}
+
+// BindEnv binds a key to env vars, which gives fine-grained control over env var names. With only a key, the env var name is derived from
+// the key using the prefix set with [WithEnvPrefix], which is applied even when called before Init. When env var names are provided they are
+// used as-is so must include any prefix. See [viper.BindEnv] for details.
+func (v *Viperlet) BindEnv(input ...string) error {
+	// the underlying viper instance always joins the prefix using an "_" so derive the name here when another separator is used
+	if len(input) == 1 && v.explicitEnvBinding() {
+		return v.Viper().BindEnv(append(input, v.envNames(input[0])...)...)
+	}
+
+	if v.envPrefix != "" {
+		v.Viper().SetEnvPrefix(v.envPrefix)
+	}
+
+	return v.Viper().BindEnv(input...)
+}
+
+// BindEnvToFlag binds the named env var to the key of the provided flag, so the value of the env var is applied to the flag by Init without
+// enabling env binding for any other flags, which is the same as using [WithFlagEnv]. This may be called multiple times for the same flag,
+// in which case the env vars take precedence in the order they were bound. An error wrapping [ErrBindEnv] is returned if the flag is nil or
+// the env var name is empty.
+func (v *Viperlet) BindEnvToFlag(envVar string, f *pflag.Flag) error {
+	if f == nil || envVar == "" {
+		return fmt.Errorf("%w: missing flag or env var", ErrBindEnv)
+	}
+
+	if v.flagEnvs == nil {
+		v.flagEnvs = make(map[string][]string)
+	}
+
+	v.flagEnvs[f.Name] = append(slices.Clone(v.flagEnvs[f.Name]), envVar)
+
+	key := v.flagKey(f.Name)
+
+	return v.bindEnvKey(append([]string{key}, v.boundEnvNames(key)...)...)
+}
+
+// newViper returns a new [*viper.Viper] instance using any options that must be set at construction time
+func (v *Viperlet) newViper() *viper.Viper {
+	if v.keyDelimiter != "" {
+		return viper.NewWithOptions(viper.KeyDelimiter(v.keyDelimiter))
+	}
+
+	return viper.New()
+}
+
+// Reset replaces the underlying [*viper.Viper] instance, including one provided using [WithViper], with a fresh instance while preserving the
+// options passed to [New], so Init can be run again from a clean slate. Any values read or bound so far are discarded and the config file is no
+// longer watched, as per [Viperlet.Close].
+//
+// Reset does not modify any flags, so as flags that had a value applied by a previous call to Init are considered changed, a fresh
+// [*pflag.FlagSet] should be passed to Init. In addition, as an [io.Reader] can only be consumed once, a config provided using
+// [WithConfigReader] will be empty when Init is run again.
+func (v *Viperlet) Reset() {
+	v.initMu.Lock()
+
+	// the watcher is only waited for once the lock is released, as a change being handled needs the lock to complete
+	done, _ := v.stopWatching()
+
+	v.setViper(v.newViper())
+	v.configRead = false
+	v.initFlagsets = nil
+	v.applied = nil
+	v.lastConfig = nil
+	v.lazyMu.Lock()
+	v.lazyLoaded = false
+	v.lazyErr = nil
+	v.lazyMu.Unlock()
+
+	v.initMu.Unlock()
+
+	if done != nil {
+		<-done
+	}
+}
+
+// Clone returns a copy of the Viperlet with the same options, along with any additional options provided, which are applied to the copy
+// only, so a configured Viperlet can be tweaked and used independently without repeating all of the options passed to [New]. Options are
+// checked for conflicts as per New, so for example a copy of a Viperlet using [WithConfig] cannot use [WithOptionalConfig].
+//
+// The copy has a fresh [*viper.Viper] instance, including when one was provided using [WithViper], so no values that have been read or bound
+// are carried over and Init must be called on the copy. Flagsets provided using [WithFlagSet] are shared, as is any [io.Reader] provided
+// using [WithConfigReader], which can only be consumed once.
+func (v *Viperlet) Clone(opts ...Option) *Viperlet {
+	c := &Viperlet{options: v.options}
+
+	// copy slices and maps so options applied to the copy do not modify the original
+	c.flagsets = slices.Clone(v.flagsets)
+	c.ignoredFlags = slices.Clone(v.ignoredFlags)
+	c.envPrefixes = slices.Clone(v.envPrefixes)
+	c.envVars = slices.Clone(v.envVars)
+	c.configPaths = slices.Clone(v.configPaths)
+	c.mergeConfigFiles = slices.Clone(v.mergeConfigFiles)
+	c.remoteProviders = slices.Clone(v.remoteProviders)
+	c.defaults = maps.Clone(v.defaults)
+	c.overrides = maps.Clone(v.overrides)
+	c.aliases = maps.Clone(v.aliases)
+	c.flagEnvs = maps.Clone(v.flagEnvs)
+	c.required = slices.Clone(v.required)
+	c.validators = slices.Clone(v.validators)
+	c.optionsUsed = maps.Clone(v.optionsUsed)
+
+	for _, o := range opts {
+		o(c)
+	}
+
+	return c
+}
+
 // Init binds the provided [*pflag.FlagSet] and env vars to the underlying [*viper.Viper] instance
+//
+// Once binding is complete, any value that is set is applied to the matching flag as a string via [pflag.FlagSet.Set], so the value must be
+// in a form the flag can parse, except for slice flags (those implementing [pflag.SliceValue]) where list values replace the flag value and
+// a single string, such as from an env var, is split on commas as per the command line.
+// Values for duration, count and bool flags are normalised first, so a number of nanoseconds, true for a count or a number for a bool are
+// applied as expected. An explicitly empty value, such as `key: ""` in a config file, is applied too so it can clear a flag default.
+// Flags that were explicitly set on the command line are never modified, as these take precedence over all other sources, unless
+// [WithEnvOverride] is used.
+//
+// Flags backed by a custom [pflag.Value], such as an enum, are handled the same way, so its Set method is called once with the string form
+// of the value and must replace, rather than add to, the current value unless the type implements [pflag.SliceValue]. A value rejected by
+// the Set method leaves the flag unchanged without an error, while a value with no string form, such as a map, is applied as an empty string.
+//
+// Required keys and validators are checked before any value is applied, so if Init returns an error no flag has been modified and Init may
+// be called again once the problem is fixed. This includes an error returned by the hook provided using [WithFlagApplyHook], where any flags
+// already modified are restored to their previous value.
+//
+// This is the same as calling InitContext with [context.Background].
 func (v *Viperlet) Init(flagset ...*pflag.FlagSet) error {
-	for _, fs := range flagset {
-		// bind *pflag.FlagSet to *viper.Viper instance
-		if err := v.Viper().BindPFlags(fs); err != nil {
-			return err
+	return v.InitContext(context.Background(), flagset...)
+}
+
+// MustInit is the same as Init however it panics if an error is returned, which is intended for use at the top level of simple programs
+// where there is nothing to do but exit on error.
+func (v *Viperlet) MustInit(flagset ...*pflag.FlagSet) {
+	if err := v.Init(flagset...); err != nil {
+		panic(fmt.Errorf("simpleviper: init: %w", err))
+	}
+}
+
+// InitContext performs the same steps as Init, however if the provided context is cancelled or its deadline is exceeded between steps then
+// the error from [context.Context.Err] is returned.
+//
+// Any [*pflag.FlagSet] provided using [WithFlagSet] is bound in addition to those passed as arguments. If any flagset is nil then
+// [ErrInvalidFlagset] is returned before anything is bound.
+//
+// Flagsets are bound in order, with those provided using [WithFlagSet] first, and when different flags with the same name are bound the
+// last one wins. As such, when using cobra, persistent flags should be passed before local flags so a local flag takes precedence. A flag
+// that is in more than one flagset, such as a persistent flag that cobra has merged into a command's flags, only has a value applied once.
+func (v *Viperlet) InitContext(ctx context.Context, flagset ...*pflag.FlagSet) error {
+	_, err := v.initContext(ctx, false, flagset)
+
+	return err
+}
+
+// InitWithReport performs the same steps as Init, and also returns a [Report] of the resolved value and source of every key, which gives
+// programmatic access to where each value came from, such as to display the provenance of config. As per [WithLogger], the source is a
+// best-effort attribution. The report reflects values before they were applied to flags.
+func (v *Viperlet) InitWithReport(flagset ...*pflag.FlagSet) (Report, error) {
+	return v.initContext(context.Background(), true, flagset)
+}
+
+// initContext implements InitContext, returning a report if requested
+func (v *Viperlet) initContext(ctx context.Context, withReport bool, flagset []*pflag.FlagSet) (Report, error) {
+	v.initMu.Lock()
+	defer v.initMu.Unlock()
+
+	// include any flagsets provided at construction time
+	flagset = append(slices.Clone(v.flagsets), flagset...)
+
+	if err := v.resolve(ctx, flagset); err != nil {
+		return nil, err
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// check required keys, collecting all missing keys so they can be reported together
+	var missing []string
+	for _, key := range v.required {
+		if !v.Viper().IsSet(key) {
+			missing = append(missing, key)
 		}
 	}
 
-	// bind to env
-	if v.bindEnv {
-		if v.envPrefix != "" {
-			v.Viper().SetEnvPrefix(v.envPrefix)
+	if len(missing) > 0 {
+		return nil, fmt.Errorf("%w: %s", ErrMissingRequired, strings.Join(missing, ", "))
+	}
+
+	// run validation once all values are merged, but before any flags are modified, so a failure leaves the flags untouched
+	for _, validate := range v.validators {
+		if err := validate(v.Viper()); err != nil {
+			return nil, err
 		}
+	}
+
+	// work out the sources before values are applied to flags
+	var report Report
+	if withReport {
+		report = v.report(flagset)
+	}
+
+	// remember the flagsets so values can be applied again by Reload
+	v.initFlagsets = flagset
 
-		if v.envKeyReplacer != nil {
-			v.Viper().SetEnvKeyReplacer(v.envKeyReplacer)
+	// set any values from viper as flags once other steps are done, which includes empty values so a flag default can be cleared
+	if !v.noPropagation {
+		if err := v.apply(flagset, nil); err != nil {
+			return nil, err
 		}
+	}
 
-		v.Viper().AutomaticEnv()
+	// report any keys from the config that did not map to a flag
+	if v.unknownKeyHandler != nil {
+		for _, key := range v.unknownConfigKeys(flagset) {
+			v.unknownKeyHandler(key)
+		}
 	}
 
-	// read in config if specified
-	if v.configFile != "" {
-		v.Viper().SetConfigFile(v.configFile)
-		if err := v.Viper().ReadInConfig(); err != nil {
-			// return all errors if allowMissingConfig is not true
-			if !v.allowMissingConfig {
-				return err
-			}
+	// write out the effective config if requested
+	if v.writeConfigFile != "" {
+		if err := v.WriteConfigAs(v.writeConfigFile); err != nil {
+			return nil, err
+		}
+	}
 
-			// otherwise only return error if it is NOT a viper.ConfigFileNotFoundError error
-			if !errors.Is(err, viper.ConfigFileNotFoundError{}) && !errors.Is(err, os.ErrNotExist) {
-				// error was something else so return it
-				return err
+	return report, nil
+}
+
+// Reload reads the config file again, along with any files provided using [WithMergeConfig], and applies the values to the flagsets that were
+// passed to the last call to Init, which is useful to reload config on demand, such as when a SIGHUP is received, rather than using [WithWatch].
+//
+// Flags that were explicitly set on the command line are never modified, as per Init, however flags that had a value applied by Init have
+// the value from the reloaded config applied. A flag for a key that is no longer set keeps its current value. Required keys and validators
+// are not checked again.
+//
+// If no config file is configured, or config is provided using [WithConfigReader], then Reload does nothing and returns nil.
+func (v *Viperlet) Reload() error {
+	v.initMu.Lock()
+	defer v.initMu.Unlock()
+
+	return v.reload(ReloadAll)
+}
+
+// Rebind binds flags that were added to the provided [*pflag.FlagSet] after Init was called, such as by plugins that register flags late, and
+// applies the resolved values to them without reading the config again, so values from env vars, config and defaults are applied as per
+// Init. Flags that were set on the command line or had a value applied by Init are not modified. The flagset is also used by Reload, if it
+// was not passed to Init.
+//
+// Keys are explicitly bound to env vars when this is required, such as when using [WithEnvPrefixes] or [WithFlagEnv], however transforms
+// such as [WithEnvTransform] are not run and required keys and validators are not checked again. An error wrapping [ErrInvalidFlagset] is
+// returned if the flagset is nil or cannot be bound.
+func (v *Viperlet) Rebind(fs *pflag.FlagSet) error {
+	if fs == nil {
+		return ErrInvalidFlagset
+	}
+
+	v.initMu.Lock()
+	defer v.initMu.Unlock()
+
+	if err := v.bindFlags(fs); err != nil {
+		return fmt.Errorf("%w: %w", ErrInvalidFlagset, err)
+	}
+
+	var keys []string
+	fs.VisitAll(func(f *pflag.Flag) {
+		if !v.ignored(f) {
+			keys = append(keys, v.flagKey(f.Name))
+		}
+	})
+
+	switch {
+	case v.envLookup != nil:
+		// values are set directly as per Init, skipping flags set on the command line
+		changed := v.changedKeys([]*pflag.FlagSet{fs})
+		for _, key := range keys {
+			if raw, ok := v.lookupEnv(key); ok && !changed[key] {
+				v.Viper().Set(key, raw)
 			}
 		}
+	case (v.bindEnv && (v.explicitEnvBinding() || v.envCaseInsensitive)) || len(v.flagEnvs) > 0:
+		if err := v.bindEnvKeys(keys); err != nil {
+			return err
+		}
 	}
 
-	// set any values from viper as flags once other steps are done
-	for _, fs := range flagset {
-		fs.VisitAll(func(f *pflag.Flag) {
-			if v.Viper().IsSet(f.Name) && v.Viper().GetString(f.Name) != "" {
-				fs.Set(f.Name, v.Viper().GetString(f.Name))
+	if !slices.Contains(v.initFlagsets, fs) {
+		v.initFlagsets = append(v.initFlagsets, fs)
+	}
+
+	if v.noPropagation {
+		return nil
+	}
+
+	return v.apply([]*pflag.FlagSet{fs}, nil)
+}
+
+// reload implements Reload, which reads the config again along with any merge files, the active profile and env vars merged as config before
+// running any transforms, so the values are always complete. Values are then applied to flags as per the mode, where for ReloadChanged they
+// are only applied for keys that have changed in the config file since it was last read, and for ReloadNone they are not applied at all.
+func (v *Viperlet) reload(mode ReloadMode) error {
+	if v.configReader != nil || !v.hasConfig() {
+		return nil
+	}
+
+	ctx, cancel := v.configContext(context.Background())
+	defer cancel()
+
+	if err := v.readConfig(ctx); err != nil {
+		return err
+	}
+
+	// keep the snapshot up to date when it is used to find changed keys, even when all values are applied
+	var keys map[string]bool
+	if mode == ReloadChanged || v.lastConfig != nil {
+		config, err := v.configSnapshot()
+		if err != nil {
+			return err
+		}
+
+		if mode == ReloadChanged {
+			keys = changedConfigKeys(v.lastConfig, config)
+		}
+		v.lastConfig = config
+	}
+
+	if err := v.mergeProfile(v.changedKeys(v.initFlagsets)); err != nil {
+		return err
+	}
+
+	if err := v.mergeEnvConfig(); err != nil {
+		return err
+	}
+
+	// flags that had a value applied are no longer treated as changed so the reloaded value takes precedence
+	if mode != ReloadNone {
+		for f := range v.applied {
+			if keys == nil || keys[v.flagKey(f.Name)] {
+				f.Changed = false
 			}
-		})
+		}
 	}
 
-	return nil
+	if err := v.transformConfig(v.changedKeys(v.initFlagsets)); err != nil {
+		return err
+	}
+
+	if mode == ReloadNone || v.noPropagation {
+		return nil
+	}
+
+	return v.apply(v.initFlagsets, keys)
 }
 
-// The Option is used to pass options to [New].
-type Option func(*Viperlet)
+// onWatchedConfigChange is run when a watched config file changes, which reads the config again as per Reload, only applying values to flags
+// as per the mode set using [WithWatchReloadMode], before running the callback provided using [WithWatch]. Changes seen by a watcher that has
+// since been stopped or replaced are ignored.
+func (v *Viperlet) onWatchedConfigChange(watcher *fsnotify.Watcher, e fsnotify.Event) {
+	v.initMu.Lock()
+	current := v.watching(watcher)
+	if current {
+		if err := v.reload(v.watchReloadMode); err != nil && v.logger != nil {
+			v.logger.Warn("reloading changed config", "error", err)
+		}
+	}
+	v.initMu.Unlock()
 
-// WithViper allows passing your own [*viper.Viper] instance
-func WithViper(viper *viper.Viper) Option {
-	return func(v *Viperlet) {
-		v.viper = viper
+	// the callback is run without holding the lock so it may call Reload
+	if !current {
+		return
+	}
+
+	if v.onConfigChange != nil {
+		v.onConfigChange(e)
 	}
 }
 
-// WithEnv enables environment variable binding. See [viper.AutomaticEnv] for details.
-func WithEnv() Option {
-	return func(v *Viperlet) {
-		v.bindEnv = true
+// configSnapshot returns the values from the config file in use, along with any files provided using [WithMergeConfig], keyed by their
+// full key, which are read using a separate [*viper.Viper] instance so values from other sources are not included
+func (v *Viperlet) configSnapshot() (map[string]any, error) {
+	c := viper.NewWithOptions(viper.KeyDelimiter(v.keyDelim()))
+	if v.configType != "" {
+		c.SetConfigType(v.configType)
+	}
+
+	files := append([]string{v.Viper().ConfigFileUsed()}, v.mergeConfigFiles...)
+	for i, path := range files {
+		c.SetConfigFile(path)
+
+		read := c.MergeInConfig
+		if i == 0 {
+			read = c.ReadInConfig
+		}
+
+		if err := read(); err != nil && (i == 0 || !v.allowMissingConfig || !isConfigNotFound(err)) {
+			return nil, configReadError(path, err)
+		}
+	}
+
+	config := make(map[string]any)
+	for _, key := range c.AllKeys() {
+		config[key] = c.Get(key)
 	}
+
+	return config, nil
 }
 
-// WithEnvPrefix enables environment variable binding using the provided prefix. See [viper.SetEnvPrefix] for details.
-func WithEnvPrefix(prefix string) Option {
-	return func(v *Viperlet) {
-		v.bindEnv = true
-		v.envPrefix = prefix
+// changedConfigKeys returns the keys that were added, removed or have a different value in the current config compared to the previous
+// config
+func changedConfigKeys(previous, current map[string]any) map[string]bool {
+	keys := make(map[string]bool)
+	for key, value := range current {
+		if old, ok := previous[key]; !ok || !reflect.DeepEqual(old, value) {
+			keys[key] = true
+		}
+	}
+
+	for key := range previous {
+		if _, ok := current[key]; !ok {
+			keys[key] = true
+		}
 	}
+
+	return keys
 }
 
-// WithEnvKeyReplacer uses the provided [*strings.Replacer] for environment variable names. See [viper.SetEnvKeyReplacer] for details.
-func WithEnvKeyReplacer(replacer *strings.Replacer) Option {
-	return func(v *Viperlet) {
-		v.bindEnv = true
-		v.envKeyReplacer = replacer
+// apply sets the resolved values from viper as flags, recording which flags had a value applied, and returns the first error from the hook
+// provided using [WithFlagApplyHook], if any, in which case any flags already modified are restored to their previous value. When keys is
+// not nil, only flags for those keys are considered.
+func (v *Viperlet) apply(flagset []*pflag.FlagSet, keys map[string]bool) error {
+	if v.applied == nil {
+		v.applied = make(map[*pflag.Flag]bool)
 	}
+
+	var hookErr error
+	seen := make(map[*pflag.Flag]bool)
+	previous := make([]flagState, 0)
+	for _, fs := range flagset {
+		fs.VisitAll(func(f *pflag.Flag) {
+			// the same flag may be in more than one flagset, such as with cobra's persistent flags, so only apply values once
+			if seen[f] || hookErr != nil || v.ignored(f) {
+				return
+			}
+			seen[f] = true
+
+			key := v.flagKey(f.Name)
+			if keys != nil && !keys[key] {
+				return
+			}
+
+			// work out the source before the flag is changed
+			var source Source
+			if v.logger != nil || v.flagApplyHook != nil {
+				source = v.valueSource(f, key)
+			}
+
+			if v.shouldApply(f, key) {
+				state := saveFlag(f, v.applied[f])
+
+				// errors are ignored, so a value that cannot be parsed leaves the flag unchanged
+				if err := v.setFlag(fs, f, key); err == nil {
+					v.applied[f] = true
+					previous = append(previous, state)
+				}
+			}
+
+			if v.logger != nil {
+				v.logger.Debug("resolved flag value", "flag", f.Name, "key", key, "value", f.Value.String(), "source", string(source))
+			}
+
+			if v.flagApplyHook != nil {
+				if err := v.flagApplyHook(f, f.Value.String(), string(source)); err != nil {
+					hookErr = fmt.Errorf("applying value for flag %q: %w", f.Name, err)
+				}
+			}
+		})
+	}
+
+	if hookErr != nil {
+		// restore in reverse order so a flag in more than one flagset ends up with its original value
+		for _, state := range slices.Backward(previous) {
+			state.restore()
+			if !state.applied {
+				delete(v.applied, state.flag)
+			}
+		}
+	}
+
+	return hookErr
 }
 
-// WithConfig enables the reading of the provided config file. All errors, including if the config file is missing are treated as a failure.
-func WithConfig(config string) Option {
-	return func(v *Viperlet) {
-		v.configFile = config
-		v.allowMissingConfig = false
+// flagState is the value of a flag before a value was applied, so the flag can be restored if a later step fails
+type flagState struct {
+	flag    *pflag.Flag
+	value   string
+	slice   []string
+	changed bool
+	applied bool
+}
+
+// saveFlag returns the current state of the flag
+func saveFlag(f *pflag.Flag, applied bool) flagState {
+	state := flagState{flag: f, value: f.Value.String(), changed: f.Changed, applied: applied}
+	if sv, ok := f.Value.(pflag.SliceValue); ok {
+		state.slice = slices.Clone(sv.GetSlice())
 	}
+
+	return state
 }
 
-// WithOptionalConfig enables the reading of the provided config file however this differs from WithConfig as a missing config file is not fatal.
-func WithOptionalConfig(config string) Option {
+// restore sets the flag back to the saved state
+func (s flagState) restore() {
+	if sv, ok := s.flag.Value.(pflag.SliceValue); ok {
+		_ = sv.Replace(s.slice)
+	} else {
+		_ = s.flag.Value.Set(s.value)
+	}
+	s.flag.Changed = s.changed
+}
+
+// DryRun performs the same resolution as Init but rather than applying values to flags, it returns a map of flag names to the value that
+// would be applied, which is useful to preview the effect of config before using it. Slice values are joined with a ",".
+//
+// The resolution is done using a fresh [*viper.Viper] instance, so neither the flags or the underlying [*viper.Viper] instance are modified,
+// however values set directly on an instance provided using [WithViper] are not included. No config watching is started, no config is
+// written and validation is not run. A dotenv file provided using [WithDotEnv] is read as per [WithEnvLookup] rather than being loaded into
+// the environment of the process. As an [io.Reader] can only be consumed once, a config provided using [WithConfigReader] will be empty
+// when Init is called afterwards.
+func (v *Viperlet) DryRun(flagset ...*pflag.FlagSet) (map[string]string, error) {
+	flagset = append(slices.Clone(v.flagsets), flagset...)
+
+	dry := v.Clone()
+	dry.watchConfig = false
+
+	// looking up env vars directly keeps the values from any dotenv file aside, so the environment of the process is not modified
+	if dry.dotEnvFile != "" && dry.envLookup == nil {
+		dry.envLookup = os.LookupEnv
+	}
+
+	if err := dry.resolve(context.Background(), flagset); err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]string)
+	for _, fs := range flagset {
+		fs.VisitAll(func(f *pflag.Flag) {
+			if key := dry.flagKey(f.Name); dry.shouldApply(f, key) {
+				values[f.Name] = dry.flagValue(f, key)
+			}
+		})
+	}
+
+	return values, nil
+}
+
+// used records that the named option was applied
+func (v *Viperlet) used(option string) {
+	if v.optionsUsed == nil {
+		v.optionsUsed = make(map[string]bool)
+	}
+
+	v.optionsUsed[option] = true
+}
+
+// checkConflicts returns an error wrapping [ErrConflictingOptions] for each pair of options that cannot be used together
+func (v *Viperlet) checkConflicts() error {
+	var errs []error
+	for _, sets := range conflictingOptions {
+		a := slices.IndexFunc(sets[0], func(option string) bool { return v.optionsUsed[option] })
+		b := slices.IndexFunc(sets[1], func(option string) bool { return v.optionsUsed[option] })
+		if a != -1 && b != -1 {
+			errs = append(errs, fmt.Errorf("%w: %s and %s", ErrConflictingOptions, sets[0][a], sets[1][b]))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// resolve binds the flagsets and env vars and reads any config, so the underlying [*viper.Viper] instance holds the resolved values, without
+// applying any values to flags
+func (v *Viperlet) resolve(ctx context.Context, flagset []*pflag.FlagSet) error {
+	if err := v.checkConflicts(); err != nil {
+		return err
+	}
+
+	if v.envFilter != "" {
+		re, err := regexp.Compile(v.envFilter)
+		if err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidEnvFilter, err)
+		}
+
+		v.envFilterRegexp = re
+	}
+
+	// in strict mode there must be something to bind
+	if v.strict && len(flagset) == 0 && !v.bindEnv && !v.hasConfig() && len(v.remoteProviders) == 0 {
+		return ErrNothingToBind
+	}
+
+	// check all flagsets are valid before binding any of them
+	if slices.Contains(flagset, nil) {
+		return ErrInvalidFlagset
+	}
+
+	for _, fs := range flagset {
+		// bind *pflag.FlagSet to *viper.Viper instance
+		if err := v.bindFlags(fs); err != nil {
+			return fmt.Errorf("%w: %w", ErrInvalidFlagset, err)
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	// set defaults
+	for key, value := range v.defaults {
+		v.Viper().SetDefault(key, value)
+	}
+
+	// bind to env
+	if v.bindEnv {
+		// load dotenv file into the environment so it follows the same env binding rules, unless a lookup function is used where the values
+		// are kept aside instead so the environment of the process is not modified
+		if v.dotEnvFile != "" {
+			var err error
+			if v.envLookup != nil {
+				v.dotEnv, err = gotenv.Read(v.dotEnvFile)
+			} else {
+				err = gotenv.Load(v.dotEnvFile)
+			}
+
+			if err != nil && (!v.allowMissingDotEnv || !isConfigNotFound(err)) {
+				return configReadError(v.dotEnvFile, err)
+			}
+		}
+
+		if v.envPrefix != "" {
+			v.Viper().SetEnvPrefix(v.envPrefix)
+		}
+
+		if replacer := v.envReplacer(); replacer != nil {
+			v.Viper().SetEnvKeyReplacer(replacer)
+		}
+
+		switch {
+		case v.envLookup != nil:
+			// the underlying viper instance always reads the environment, so values are set directly once all keys are known
+		case v.explicitEnvBinding():
+			// binding for multiple prefixes, a custom separator or a filter is done once all keys are known after reading config
+		case len(v.envVars) > 0:
+			// only bind the specific keys if provided
+			var errs []error
+			for _, key := range v.envVars {
+				errs = append(errs, v.bindEnvKey(key))
+			}
+
+			if err := v.envBindingError(errs); err != nil {
+				return err
+			}
+		default:
+			// otherwise bind everything
+			v.Viper().AutomaticEnv()
+		}
+	}
+
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	if err := func() error {
+		ctx, cancel := v.configContext(ctx)
+		defer cancel()
+
+		// reading config is deferred until a value is first looked up when using WithLazyConfig
+		if !v.lazyConfig {
+			if err := v.loadConfig(ctx); err != nil {
+				return err
+			}
+		}
+
+		return v.readRemoteConfig(ctx)
+	}(); err != nil {
+		return err
+	}
+
+	// merge the active profile over the config before env vars so env vars still take precedence
+	if !v.lazyConfig {
+		if err := v.mergeProfile(v.changedKeys(flagset)); err != nil {
+			return err
+		}
+	}
+
+	// merge env vars in as config values after any config files so they take precedence
+	if err := v.mergeEnvConfig(); err != nil {
+		return err
+	}
+
+	// bind env vars using each prefix, a custom separator, a filter or ignoring case, now that keys from the config are known
+	if v.bindEnv && v.envLookup == nil && (v.explicitEnvBinding() || v.envCaseInsensitive) {
+		keys := v.envVars
+		if len(keys) == 0 {
+			keys = append(v.Viper().AllKeys(), v.filteredEnvKeys()...)
+		}
+
+		if err := v.bindEnvKeys(keys); err != nil {
+			return err
+		}
+	}
+
+	// bind the env vars provided for specific flags, which replaces any binding done above so the other env vars are included too
+	if len(v.flagEnvs) > 0 && v.envLookup == nil {
+		var keys []string
+		for name := range v.flagEnvs {
+			keys = append(keys, v.flagKey(name))
+		}
+
+		if err := v.bindEnvKeys(keys); err != nil {
+			return err
+		}
+	}
+
+	// register aliases once config has been read so values under an alias are moved to the real key before values are applied to flags
+	for alias, key := range v.aliases {
+		v.Viper().RegisterAlias(alias, key)
+	}
+
+	// find the keys of flags set on the command line
+	changed := v.changedKeys(flagset)
+
+	// when using a custom lookup function, set the value of the env var for each key as an override, skipping keys with a flag set on the
+	// command line as these are handled below when env vars take precedence
+	if v.envLookup != nil {
+		for _, key := range append(v.Viper().AllKeys(), v.filteredEnvKeys()...) {
+			if raw, ok := v.lookupEnv(key); ok && !changed[key] {
+				v.Viper().Set(key, raw)
+			}
+		}
+	}
+
+	// when env vars take precedence over the command line, set the env var value as an override for flags that were set
+	if v.envOverride {
+		for key := range changed {
+			if raw, ok := v.lookupEnv(key); ok {
+				v.Viper().Set(key, raw)
+			}
+		}
+	}
+
+	// transform env sourced values, skipping any keys with a flag set on the command line unless env vars take precedence
+	if v.bindEnv && v.envTransform != nil {
+		for _, key := range v.Viper().AllKeys() {
+			raw, ok := v.lookupEnv(key)
+			if !ok || (changed[key] && !v.envOverride) {
+				continue
+			}
+
+			value, err := v.envTransform(key, raw)
+			if err != nil {
+				return fmt.Errorf("transforming env var for %q: %w", key, err)
+			}
+
+			v.Viper().Set(key, value)
+		}
+	}
+
+	if err := v.transformConfig(changed); err != nil {
+		return err
+	}
+
+	// overrides are set last so they take precedence over everything, including any transformed values
+	for key, value := range v.overrides {
+		v.Viper().Set(key, value)
+	}
+
+	return nil
+}
+
+// loadConfig reads in config from the [io.Reader] provided using [WithConfigReader], which replaces any config file, otherwise reads in the
+// config file if specified, then starts watching the config file if required
+func (v *Viperlet) loadConfig(ctx context.Context) error {
+	if v.configReader != nil {
+		configType := v.configReaderType
+		if configType == "" {
+			configType = v.configType
+		}
+
+		v.Viper().SetConfigType(configType)
+		if err := v.Viper().ReadConfig(v.configReader); err != nil {
+			return configReadError("", err)
+		}
+
+		if err := v.checkNonEmpty(""); err != nil {
+			return err
+		}
+	} else if err := v.readConfig(ctx); err != nil {
+		return err
+	}
+
+	// only watch for changes once the config file has been read successfully
+	if v.configRead && v.watchConfig {
+		if v.watchReloadMode == ReloadChanged {
+			config, err := v.configSnapshot()
+			if err != nil {
+				return err
+			}
+
+			v.lastConfig = config
+		}
+
+		if err := v.watch(); err != nil {
+			return fmt.Errorf("watching config: %w", err)
+		}
+	}
+
+	return nil
+}
+
+// lazyLoad reads the config on first use when [WithLazyConfig] is used, returning the error from reading the config, if any, on every call
+func (v *Viperlet) lazyLoad() error {
+	if !v.lazyConfig {
+		return nil
+	}
+
+	v.lazyMu.Lock()
+	defer v.lazyMu.Unlock()
+
+	if v.lazyLoaded {
+		return v.lazyErr
+	}
+	v.lazyLoaded = true
+
+	v.lazyErr = func() error {
+		ctx, cancel := v.configContext(context.Background())
+		defer cancel()
+
+		if err := v.loadConfig(ctx); err != nil {
+			return err
+		}
+
+		if err := v.mergeProfile(v.changedKeys(v.initFlagsets)); err != nil {
+			return err
+		}
+
+		// reading the config replaces any env vars merged as config by Init
+		if err := v.mergeEnvConfig(); err != nil {
+			return err
+		}
+
+		return v.transformConfig(v.changedKeys(v.initFlagsets))
+	}()
+
+	return v.lazyErr
+}
+
+// ConfigError returns the error from reading the config when [WithLazyConfig] is used, reading the config first if this has not been done,
+// which is needed as getters such as GetString do not return an error. This always returns nil when WithLazyConfig is not used, as any error
+// reading the config is returned by Init.
+func (v *Viperlet) ConfigError() error {
+	return v.lazyLoad()
+}
+
+// readConfig reads the config file, if one is configured, along with any additional files to merge
+func (v *Viperlet) readConfig(ctx context.Context) error {
+	if v.configFS != nil {
+		return v.readConfigFS()
+	}
+
+	if v.configURL != "" {
+		return v.readConfigURL(ctx)
+	}
+
+	if v.configEncodedEnv != "" {
+		if raw, _ := v.getenv(v.configEncodedEnv); raw != "" {
+			return v.readEncodedEnvConfig(raw)
+		}
+	}
+
+	// locate the config file using an env var, which is done after loading any dotenv file so the path can be set there
+	configFile, allowMissingConfig := v.configFile, v.allowMissingConfig
+	if v.configFileEnv != "" {
+		if path, _ := v.getenv(v.configFileEnv); path != "" {
+			configFile, allowMissingConfig = path, true
+		}
+	}
+
+	// fall back to a config file next to the executable
+	if configFile == "" && v.configExeName != "" {
+		path, err := executableDir()
+		if err != nil {
+			return configReadError(v.configExeName, err)
+		}
+
+		configFile, allowMissingConfig = filepath.Join(path, v.configExeName), true
+	}
+
+	// without any other config file the fallback is used directly
+	if configFile == "" && v.configName == "" {
+		if v.fallbackConfigFile == "" {
+			return nil
+		}
+
+		configFile = v.fallbackConfigFile
+	}
+
+	// a config file takes precedence over searching, as setting the config name clears the config file
+	if configFile != "" {
+		v.Viper().SetConfigFile(configFile)
+	} else {
+		v.Viper().SetConfigName(v.configName)
+		for _, path := range v.configPaths {
+			v.Viper().AddConfigPath(path)
+		}
+	}
+
+	// the config type is not used when searching for a config file in any format, as the type is inferred from the file found
+	if v.configType != "" && (configFile != "" || !v.configAnyFormat) {
+		v.Viper().SetConfigType(v.configType)
+	}
+
+	if configFile != "" {
+		if err := statConfig(ctx, configFile); err != nil {
+			return err
+		}
+
+		if err := v.checkConfigSize(configFile, os.Stat); err != nil {
+			return err
+		}
+	} else if v.maxConfigSize > 0 {
+		if path := v.searchConfigFile(); path != "" {
+			if err := v.checkConfigSize(path, os.Stat); err != nil {
+				return err
+			}
+		}
+	}
+
+	v.configRead = true
+	err := v.Viper().ReadInConfig()
+
+	// try the fallback config file only when the config file was not found, so other errors are still returned
+	if err != nil && v.fallbackConfigFile != "" && configFile != v.fallbackConfigFile && isConfigNotFound(err) {
+		if err := statConfig(ctx, v.fallbackConfigFile); err != nil {
+			return err
+		}
+
+		if err := v.checkConfigSize(v.fallbackConfigFile, os.Stat); err != nil {
+			return err
+		}
+
+		configFile = v.fallbackConfigFile
+		v.Viper().SetConfigFile(configFile)
+		err = v.Viper().ReadInConfig()
+	}
+
+	if err != nil {
+		// return all errors if missing config is not allowed, otherwise only return error if the config file was found
+		if !allowMissingConfig || !isConfigNotFound(err) {
+			// use the config name when the file was searched for
+			if configFile == "" {
+				return configReadError(v.configName, err)
+			}
+
+			return configReadError(configFile, err)
+		}
+
+		v.configRead = false
+	}
+
+	// merge in any additional config files in order so later files take precedence
+	for _, path := range v.mergeConfigFiles {
+		if err := statConfig(ctx, path); err != nil {
+			return err
+		}
+
+		if err := v.checkConfigSize(path, os.Stat); err != nil {
+			return err
+		}
+
+		v.Viper().SetConfigFile(path)
+		if err := v.Viper().MergeInConfig(); err != nil {
+			if !allowMissingConfig || !isConfigNotFound(err) {
+				return configReadError(path, err)
+			}
+		}
+	}
+
+	// point back at the original config file after merging
+	if len(v.mergeConfigFiles) > 0 && configFile != "" {
+		v.Viper().SetConfigFile(configFile)
+	}
+
+	if v.configRead {
+		// use the config name when the file was searched for
+		if configFile == "" {
+			return v.checkNonEmpty(v.configName)
+		}
+
+		return v.checkNonEmpty(configFile)
+	}
+
+	return nil
+}
+
+// checkNonEmpty returns an error wrapping [ErrEmptyConfig] if [WithNonEmptyConfig] is used and no keys were read from the named config
+func (v *Viperlet) checkNonEmpty(name string) error {
+	if !v.nonEmptyConfig || slices.ContainsFunc(v.Viper().AllKeys(), v.Viper().InConfig) {
+		return nil
+	}
+
+	return configReadError(name, ErrEmptyConfig)
+}
+
+// readConfigFS reads the config from the file in the filesystem provided using [WithConfigFS] or [WithOptionalConfigFS]
+func (v *Viperlet) readConfigFS() error {
+	f, err := v.configFS.Open(v.configFSName)
+	if err != nil {
+		if v.allowMissingConfig && isConfigNotFound(err) {
+			return nil
+		}
+
+		return configReadError(v.configFSName, err)
+	}
+	defer f.Close()
+
+	if err := v.checkConfigSize(v.configFSName, func(string) (fs.FileInfo, error) { return f.Stat() }); err != nil {
+		return err
+	}
+
+	// infer the type from the extension unless a type was set
+	configType := v.configType
+	if configType == "" {
+		configType = strings.TrimPrefix(path.Ext(v.configFSName), ".")
+	}
+
+	v.Viper().SetConfigType(configType)
+	if err := v.Viper().ReadConfig(f); err != nil {
+		return configReadError(v.configFSName, err)
+	}
+
+	return v.checkNonEmpty(v.configFSName)
+}
+
+// readConfigURL fetches the config from the URL provided using [WithConfigURL] or [WithOptionalConfigURL]
+func (v *Viperlet) readConfigURL(ctx context.Context) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.configURL, nil)
+	if err != nil {
+		return configReadError(v.configURL, err)
+	}
+
+	client := v.httpClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return configReadError(v.configURL, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound, http.StatusGone:
+		if v.allowMissingConfig {
+			return nil
+		}
+
+		return configReadError(v.configURL, fmt.Errorf("%w: %s", os.ErrNotExist, resp.Status))
+	default:
+		return configReadError(v.configURL, fmt.Errorf("unexpected status: %s", resp.Status))
+	}
+
+	// read at most one byte more than the maximum size so a body that is too large can be detected
+	body := io.Reader(resp.Body)
+	if v.maxConfigSize > 0 {
+		body = io.LimitReader(resp.Body, v.maxConfigSize+1)
+	}
+
+	data, err := io.ReadAll(body)
+	if err != nil {
+		return configReadError(v.configURL, err)
+	}
+
+	if v.maxConfigSize > 0 && int64(len(data)) > v.maxConfigSize {
+		return configReadError(v.configURL, fmt.Errorf("%w: exceeds the maximum of %d bytes", ErrConfigTooLarge, v.maxConfigSize))
+	}
+
+	// infer the type from the extension of the path unless a type was set
+	configType := v.configURLType
+	if configType == "" {
+		configType = v.configType
+	}
+	if configType == "" {
+		if u, err := url.Parse(v.configURL); err == nil {
+			configType = strings.TrimPrefix(path.Ext(u.Path), ".")
+		}
+	}
+
+	v.Viper().SetConfigType(configType)
+	if err := v.Viper().ReadConfig(bytes.NewReader(data)); err != nil {
+		return configReadError(v.configURL, err)
+	}
+
+	return v.checkNonEmpty(v.configURL)
+}
+
+// readEncodedEnvConfig decodes the config from the env var provided using [WithConfigFromEncodedEnv] and reads it into the underlying
+// [*viper.Viper] instance
+func (v *Viperlet) readEncodedEnvConfig(raw string) error {
+	data := []byte(raw)
+	if v.configEncoding&EncodingBase64 != 0 {
+		decoded, err := base64.StdEncoding.DecodeString(raw)
+		if err != nil {
+			return configReadError(v.configEncodedEnv, fmt.Errorf("decoding base64: %w", err))
+		}
+
+		data = decoded
+	}
+
+	if v.configEncoding&EncodingGzip != 0 {
+		zr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			return configReadError(v.configEncodedEnv, fmt.Errorf("decompressing gzip: %w", err))
+		}
+		defer zr.Close()
+
+		// read at most one byte more than the maximum size so config that is too large once decompressed can be detected
+		r := io.Reader(zr)
+		if v.maxConfigSize > 0 {
+			r = io.LimitReader(zr, v.maxConfigSize+1)
+		}
+
+		data, err = io.ReadAll(r)
+		if err != nil {
+			return configReadError(v.configEncodedEnv, fmt.Errorf("decompressing gzip: %w", err))
+		}
+	}
+
+	if v.maxConfigSize > 0 && int64(len(data)) > v.maxConfigSize {
+		return configReadError(v.configEncodedEnv, fmt.Errorf("%w: exceeds the maximum of %d bytes", ErrConfigTooLarge, v.maxConfigSize))
+	}
+
+	configType := v.configEncodedType
+	if configType == "" {
+		configType = v.configType
+	}
+
+	v.Viper().SetConfigType(configType)
+	if err := v.Viper().ReadConfig(bytes.NewReader(data)); err != nil {
+		return configReadError(v.configEncodedEnv, err)
+	}
+
+	return v.checkNonEmpty(v.configEncodedEnv)
+}
+
+// configContext returns a context that is cancelled once the timeout set using [WithTimeout] has passed, if any
+func (v *Viperlet) configContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if v.configTimeout <= 0 {
+		return ctx, func() {}
+	}
+
+	return context.WithTimeout(ctx, v.configTimeout)
+}
+
+// statConfig guards against a config file on a filesystem that hangs, such as an unresponsive network filesystem, by checking the file can be
+// accessed before the context is done, as reading the file itself cannot be cancelled. Any error from stat is ignored, so that reading the
+// file returns the error as usual.
+func statConfig(ctx context.Context, name string) error {
+	if ctx.Done() == nil {
+		return nil
+	}
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		_, _ = os.Stat(name)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return configReadError(name, ctx.Err())
+	}
+}
+
+// checkConfigSize returns an error wrapping [ErrConfigTooLarge] if a maximum size is set using [WithMaxConfigSize] and the config file is
+// larger than this. Any error from stat is ignored, so that reading the file returns the error as usual.
+func (v *Viperlet) checkConfigSize(name string, stat func(name string) (fs.FileInfo, error)) error {
+	if v.maxConfigSize <= 0 {
+		return nil
+	}
+
+	info, err := stat(name)
+	if err != nil || !info.Mode().IsRegular() {
+		return nil
+	}
+
+	if info.Size() > v.maxConfigSize {
+		return configReadError(name, fmt.Errorf("%w: %d bytes exceeds the maximum of %d bytes", ErrConfigTooLarge, info.Size(), v.maxConfigSize))
+	}
+
+	return nil
+}
+
+// searchConfigFile returns the config file that viper would find when searching using [WithConfigName], or an empty string if there is none,
+// which follows the same order as viper by trying each of the supported extensions in each path before the name without an extension
+func (v *Viperlet) searchConfigFile() string {
+	for _, path := range v.configPaths {
+		path = absConfigPath(path)
+
+		for _, ext := range viper.SupportedExts {
+			if name := filepath.Join(path, v.configName+"."+ext); isFile(name) {
+				return name
+			}
+		}
+
+		if v.configType != "" && !v.configAnyFormat {
+			if name := filepath.Join(path, v.configName); isFile(name) {
+				return name
+			}
+		}
+	}
+
+	return ""
+}
+
+// absConfigPath resolves a config path in the same way as viper, which expands a leading "$HOME" and any environment variables before making
+// the path absolute
+func absConfigPath(path string) string {
+	if path == "$HOME" || strings.HasPrefix(path, "$HOME"+string(os.PathSeparator)) {
+		if home, err := os.UserHomeDir(); err == nil {
+			path = home + path[5:]
+		}
+	}
+
+	path = os.ExpandEnv(path)
+	if abs, err := filepath.Abs(path); err == nil {
+		return abs
+	}
+
+	return filepath.Clean(path)
+}
+
+// isFile reports whether name exists and is not a directory
+func isFile(name string) bool {
+	info, err := os.Stat(name)
+
+	return err == nil && !info.IsDir()
+}
+
+// readRemoteConfig adds the remote providers and reads the config from the first that succeeds, where a failure to read is only ignored if all
+// providers are optional
+func (v *Viperlet) readRemoteConfig(ctx context.Context) error {
+	if len(v.remoteProviders) == 0 {
+		return nil
+	}
+
+	optional := true
+	for _, p := range v.remoteProviders {
+		var err error
+		if p.secretKeyring != "" {
+			err = v.Viper().AddSecureRemoteProvider(p.provider, p.endpoint, p.path, p.secretKeyring)
+		} else {
+			err = v.Viper().AddRemoteProvider(p.provider, p.endpoint, p.path)
+		}
+
+		if err != nil {
+			return configReadError(p.path, err)
+		}
+
+		optional = optional && p.optional
+	}
+
+	// remote support is not enabled unless viper/remote is imported, which is always an error
+	if viper.RemoteConfig == nil {
+		return configReadError(v.remoteProviders[0].path, errors.New("remote support is not enabled, import github.com/spf13/viper/remote"))
+	}
+
+	if v.configType != "" {
+		v.Viper().SetConfigType(v.configType)
+	}
+
+	// a timeout is always returned, even when all providers are optional, as the underlying viper instance has been replaced
+	if err := v.readRemote(ctx); err != nil && (!optional || ctx.Err() != nil) {
+		return configReadError(v.remoteProviders[0].path, err)
+	}
+
+	return nil
+}
+
+// readRemote reads the config from the remote providers, returning the error from the context if it is done first. As the read itself cannot
+// be cancelled, the underlying [*viper.Viper] instance is replaced in that case, as per Reset, so the abandoned read cannot modify it.
+func (v *Viperlet) readRemote(ctx context.Context) error {
+	if ctx.Done() == nil {
+		return v.Viper().ReadRemoteConfig()
+	}
+
+	vp := v.Viper()
+	errc := make(chan error, 1)
+	go func() {
+		errc <- vp.ReadRemoteConfig()
+	}()
+
+	select {
+	case err := <-errc:
+		return err
+	case <-ctx.Done():
+		v.setViper(v.newViper())
+		v.configRead = false
+
+		return ctx.Err()
+	}
+}
+
+// hasConfig returns true if any source of config is set
+func (v *Viperlet) hasConfig() bool {
+	return v.configFile != "" || v.configName != "" || v.configFileEnv != "" || v.configExeName != "" || v.configReader != nil ||
+		v.configFS != nil || v.configURL != "" || v.fallbackConfigFile != "" || v.configEncodedEnv != ""
+}
+
+// executableDir returns the directory containing the executable, after resolving any symlinks
+func executableDir() (string, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return "", err
+	}
+
+	exe, err = filepath.EvalSymlinks(exe)
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Dir(exe), nil
+}
+
+// mergeProfile merges the values of the active profile over the config when [WithProfile] is used, where changed holds the keys of flags set
+// on the command line
+func (v *Viperlet) mergeProfile(changed map[string]bool) error {
+	if v.profileKey == "" {
+		return nil
+	}
+
+	// the env var is looked up directly as it may not be bound yet, such as when using WithEnvPrefixes or WithFlagEnv, or never seen by the
+	// underlying viper instance when using WithEnvLookup, with a flag set on the command line taking precedence unless env vars do
+	name := v.Viper().GetString(v.profileKey)
+	if !changed[v.profileKey] || v.envOverride {
+		if raw, ok := v.lookupEnv(v.profileKey); ok {
+			name = raw
+		}
+	}
+
+	if name == "" {
+		return nil
+	}
+
+	key := "profiles" + v.keyDelim() + name
+	if !v.Viper().InConfig(key) {
+		return fmt.Errorf("%w: %s", ErrUnknownProfile, name)
+	}
+
+	return v.Viper().MergeConfigMap(v.Viper().GetStringMap(key))
+}
+
+// mergeEnvConfig merges env vars into the config when [WithEnvAsConfig] is used
+func (v *Viperlet) mergeEnvConfig() error {
+	if v.envAsConfigPrefix == "" {
+		return nil
+	}
+
+	return v.Viper().MergeConfigMap(v.envConfig(v.envAsConfigPrefix))
+}
+
+// bindEnvKey binds the key, which is the first input, to env vars returning an error wrapping [ErrBindEnv] if the binding fails or the key is
+// empty
+func (v *Viperlet) bindEnvKey(input ...string) error {
+	if len(input) == 0 || input[0] == "" {
+		return fmt.Errorf("%w: empty key", ErrBindEnv)
+	}
+
+	if err := v.Viper().BindEnv(input...); err != nil {
+		return fmt.Errorf("%w %q: %w", ErrBindEnv, input[0], err)
+	}
+
+	return nil
+}
+
+// bindEnvKeys binds each key to the env vars returned by boundEnvNames, skipping keys where there are none, such as when every env var name
+// for the key was excluded by the filter provided using [WithEnvFilter]
+func (v *Viperlet) bindEnvKeys(keys []string) error {
+	var errs []error
+	for _, key := range keys {
+		names := v.boundEnvNames(key)
+		if len(names) == 0 {
+			continue
+		}
+
+		errs = append(errs, v.bindEnvKey(append([]string{key}, names...)...))
+	}
+
+	return v.envBindingError(errs)
+}
+
+// envBindingError returns all errors from binding env vars joined together, unless binding errors are not fatal in which case these are
+// logged instead
+func (v *Viperlet) envBindingError(errs []error) error {
+	err := errors.Join(errs...)
+	if err == nil || !v.lenientEnvBinding {
+		return err
+	}
+
+	if v.logger != nil {
+		v.logger.Warn("ignoring env binding error", "error", err)
+	}
+
+	return nil
+}
+
+// changedKeys returns the keys of flags that were set on the command line
+func (v *Viperlet) changedKeys(flagset []*pflag.FlagSet) map[string]bool {
+	changed := make(map[string]bool)
+	for _, fs := range flagset {
+		fs.Visit(func(f *pflag.Flag) {
+			changed[v.flagKey(f.Name)] = true
+		})
+	}
+
+	return changed
+}
+
+// transformConfig expands env vars in string values from config files when [WithEnvExpansion] is used, and then decrypts them when
+// [WithValueDecryptor] is used, so that a decrypted value is never expanded
+func (v *Viperlet) transformConfig(changed map[string]bool) error {
+	if v.envExpansion {
+		if err := v.mapConfig(changed, v.expandEnv); err != nil {
+			return err
+		}
+	}
+
+	if v.valueDecryptor != nil {
+		if err := v.mapConfig(changed, func(key, raw string) (string, error) {
+			value, err := v.valueDecryptor(key, []byte(raw))
+			if err != nil {
+				return "", fmt.Errorf("decrypting value for %q: %w", key, err)
+			}
+
+			return string(value), nil
+		}); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// mapConfig calls fn for each string value from config files, skipping keys where the value comes from a flag set on the command line or an
+// env var, and merges any changed values back into the config
+func (v *Viperlet) mapConfig(changed map[string]bool, fn func(key, raw string) (string, error)) error {
+	config := make(map[string]any)
+	for _, key := range v.Viper().AllKeys() {
+		if !v.Viper().InConfig(key) || changed[key] || v.envIsSet(key) {
+			continue
+		}
+
+		raw, ok := v.Viper().Get(key).(string)
+		if !ok {
+			continue
+		}
+
+		value, err := fn(key, raw)
+		if err != nil {
+			return err
+		}
+
+		if value != raw {
+			setNested(config, strings.Split(key, v.keyDelim()), value)
+		}
+	}
+
+	if len(config) == 0 {
+		return nil
+	}
+
+	return v.Viper().MergeConfigMap(config)
+}
+
+// expandEnv replaces references to env vars in the value for the key, returning an error wrapping [ErrUndefinedEnvVar] if any are not set
+// when [WithStrictEnvExpansion] is used
+func (v *Viperlet) expandEnv(key, raw string) (string, error) {
+	var undefined []string
+	value := os.Expand(raw, func(name string) string {
+		value, ok := v.getenv(name)
+		if !ok {
+			undefined = append(undefined, name)
+		}
+
+		return value
+	})
+
+	if v.strictEnvExpansion && len(undefined) > 0 {
+		return "", fmt.Errorf("%w in value for %q: %s", ErrUndefinedEnvVar, key, strings.Join(undefined, ", "))
+	}
+
+	return value, nil
+}
+
+// shouldApply returns true if the resolved value for the key should be applied to the flag
+func (v *Viperlet) shouldApply(f *pflag.Flag, key string) bool {
+	if v.ignored(f) {
+		return false
+	}
+
+	// flags set on the command line take precedence so are left untouched, unless env vars or an override take precedence
+	if f.Changed && !(v.envOverride && v.envIsSet(key)) && !v.overridden(key) {
+		return false
+	}
+
+	return v.Viper().IsSet(key)
+}
+
+// envReplacer returns the replacer for env var names set using WithEnvKeyReplacer or WithEnvKeyReplacerDefault, if any
+func (v *Viperlet) envReplacer() *strings.Replacer {
+	if v.envKeyReplacer != nil {
+		return v.envKeyReplacer
+	}
+
+	if v.defaultEnvKeyReplacer {
+		return strings.NewReplacer(".", "_", "-", "_")
+	}
+
+	return nil
+}
+
+// lookupEnv returns the value of the env var bound to the key, following the same naming rules as the underlying [*viper.Viper] instance
+func (v *Viperlet) lookupEnv(key string) (string, bool) {
+	for _, name := range v.boundEnvNames(key) {
+		if value, ok := v.getenv(name); ok && value != "" {
+			return value, true
+		}
+	}
+
+	return "", false
+}
+
+// getenv returns the value of the named env var using the function provided using [WithEnvLookup], followed by the values from any dotenv
+// file, or [os.LookupEnv] if no function was provided
+func (v *Viperlet) getenv(name string) (string, bool) {
+	if v.envLookup != nil {
+		if value, ok := v.envLookup(name); ok {
+			return value, true
+		}
+
+		// values from a dotenv file never override those from the lookup function, as per loading it into the environment
+		value, ok := v.dotEnv[name]
+
+		return value, ok
+	}
+
+	return os.LookupEnv(name)
+}
+
+// boundEnvNames returns the names of the env vars bound to the key in order of precedence, which are the names derived from the key when
+// env binding is enabled followed by any provided using [WithFlagEnv]
+func (v *Viperlet) boundEnvNames(key string) []string {
+	var names []string
+	if v.bindEnv && (len(v.envVars) == 0 || slices.Contains(v.envVars, key)) {
+		names = v.filterEnvNames(v.envNames(key))
+	}
+
+	for name, envVars := range v.flagEnvs {
+		if v.flagKey(name) == key {
+			names = append(names, envVars...)
+		}
+	}
+
+	if v.envCaseInsensitive {
+		names = append(names, foldedEnvNames(names)...)
+	}
+
+	return names
+}
+
+// envNames returns the env var names for the key in order of precedence, which includes any prefix with the env key replacer applied
+func (v *Viperlet) envNames(key string) []string {
+	var names []string
+	switch {
+	case len(v.envPrefixes) > 0:
+		names = v.prefixedEnvNames(key)
+	case v.envPrefix != "":
+		names = []string{strings.ToUpper(v.envPrefix + v.envSeparator() + key)}
+	default:
+		names = []string{strings.ToUpper(key)}
+	}
+
+	if replacer := v.envReplacer(); replacer != nil {
+		for i, name := range names {
+			names[i] = replacer.Replace(name)
+		}
+	}
+
+	return names
+}
+
+// foldedEnvNames returns the names of env vars that are set which match any of the provided names when compared case-insensitively, excluding
+// exact matches
+func foldedEnvNames(names []string) []string {
+	var folded []string
+	for _, env := range os.Environ() {
+		name, _, _ := strings.Cut(env, "=")
+		for _, match := range names {
+			if name != match && strings.EqualFold(name, match) {
+				folded = append(folded, name)
+			}
+		}
+	}
+
+	return folded
+}
+
+// envIsSet returns true if an env var bound to the key is set
+func (v *Viperlet) envIsSet(key string) bool {
+	_, ok := v.lookupEnv(key)
+
+	return ok
+}
+
+// overridden returns true if the key was provided using [WithOverrides]
+func (v *Viperlet) overridden(key string) bool {
+	_, ok := v.overrides[strings.ToLower(key)]
+
+	return ok
+}
+
+// valueSource makes a best-effort attempt to work out where the value for a flag with the provided key came from, where the flag is nil for
+// keys that are not bound to a flag
+func (v *Viperlet) valueSource(f *pflag.Flag, key string) Source {
+	switch {
+	case v.overridden(key):
+		return SourceOverride
+	case v.envOverride && v.envIsSet(key):
+		return SourceEnv
+	case f != nil && f.Changed:
+		return SourceFlag
+	case !v.Viper().IsSet(key):
+		return SourceDefault
+	case v.envIsSet(key):
+		return SourceEnv
+	case v.Viper().InConfig(key):
+		return SourceConfig
+	}
+
+	return SourceDefault
+}
+
+// report returns the resolved value and source of every key, which must be done before values are applied to flags
+func (v *Viperlet) report(flagset []*pflag.FlagSet) Report {
+	flags := make(map[string]*pflag.Flag)
+	for _, fs := range flagset {
+		fs.VisitAll(func(f *pflag.Flag) {
+			if !v.ignored(f) {
+				flags[v.flagKey(f.Name)] = f
+			}
+		})
+	}
+
+	report := make(Report)
+	for _, key := range v.Viper().AllKeys() {
+		report[key] = ReportEntry{Value: v.Viper().Get(key), Source: v.valueSource(flags[key], key)}
+	}
+
+	return report
+}
+
+// envSeparator returns the separator between the env var prefix and key, which is an "_" unless set using [WithEnvPrefixSeparator]
+func (v *Viperlet) envSeparator() string {
+	if v.envPrefixSeparator != "" {
+		return v.envPrefixSeparator
+	}
+
+	return "_"
+}
+
+// explicitEnvBinding returns true if env vars must be explicitly bound for each key, as [viper.AutomaticEnv] only supports a single prefix
+// joined to the key using an "_" and cannot filter env vars
+func (v *Viperlet) explicitEnvBinding() bool {
+	return len(v.envPrefixes) > 0 || (v.envPrefix != "" && v.envSeparator() != "_") || v.envFilter != ""
+}
+
+// filterEnvNames returns the env var names that match the pattern provided using [WithEnvFilter], or all of the names if no filter is set
+func (v *Viperlet) filterEnvNames(names []string) []string {
+	if v.envFilterRegexp == nil {
+		return names
+	}
+
+	return slices.DeleteFunc(names, func(name string) bool {
+		return !v.envFilterRegexp.MatchString(name)
+	})
+}
+
+// filteredEnvKeys returns the keys for env vars that are set and match the pattern provided using [WithEnvFilter], where the key is the
+// lowercased name of the env var without any prefix, so env vars that are not a known key can still be bound
+func (v *Viperlet) filteredEnvKeys() []string {
+	if v.envFilterRegexp == nil {
+		return nil
+	}
+
+	prefixes := v.envPrefixes
+	if v.envPrefix != "" {
+		prefixes = []string{v.envPrefix}
+	}
+
+	var keys []string
+	for _, env := range os.Environ() {
+		name, _, _ := strings.Cut(env, "=")
+		if !v.envFilterRegexp.MatchString(name) {
+			continue
+		}
+
+		for _, prefix := range prefixes {
+			if trimmed, ok := strings.CutPrefix(name, strings.ToUpper(prefix+v.envSeparator())); ok && trimmed != "" {
+				name = trimmed
+				break
+			}
+		}
+
+		keys = append(keys, strings.ToLower(name))
+	}
+
+	return keys
+}
+
+// prefixedEnvNames returns the env var names for the key using each of the prefixes provided by WithEnvPrefixes in order
+func (v *Viperlet) prefixedEnvNames(key string) []string {
+	names := make([]string, 0, len(v.envPrefixes))
+	for _, prefix := range v.envPrefixes {
+		names = append(names, strings.ToUpper(prefix+v.envSeparator()+key))
+	}
+
+	return names
+}
+
+// setFlag applies the value for the provided key to the flag
+func (v *Viperlet) setFlag(fs *pflag.FlagSet, f *pflag.Flag, key string) error {
+	// slice flags have their value replaced as a whole so list values are not mangled by being converted to a single string
+	if sv, ok := f.Value.(pflag.SliceValue); ok {
+		if err := sv.Replace(v.sliceValue(key)); err != nil {
+			return err
+		}
+		f.Changed = true
+
+		return nil
+	}
+
+	return fs.Set(f.Name, v.flagValue(f, key))
+}
+
+// rawValue returns the value for the provided key, with the normaliser provided using [WithStringNormalizer] applied if it is a string
+func (v *Viperlet) rawValue(key string) any {
+	value := v.Viper().Get(key)
+	if s, ok := value.(string); ok && v.stringNormalizer != nil {
+		return v.stringNormalizer(s)
+	}
+
+	return value
+}
+
+// sliceValue returns the value for the provided key as it would be applied to a slice flag, where a single string, such as from an env var,
+// is split on commas the same way as pflag does for values on the command line, rather than on whitespace as per [viper.GetStringSlice]
+func (v *Viperlet) sliceValue(key string) []string {
+	values := splitSlice(v.rawValue(key))
+	if v.stringNormalizer != nil {
+		// the slice may be shared with the underlying viper instance
+		values = slices.Clone(values)
+		for i, s := range values {
+			values[i] = v.stringNormalizer(s)
+		}
+	}
+
+	return values
+}
+
+// splitSlice converts the value to a slice of strings, splitting a string on commas
+func splitSlice(value any) []string {
+	raw, ok := value.(string)
+	if !ok {
+		return cast.ToStringSlice(value)
+	}
+
+	if raw == "" {
+		return []string{}
+	}
+
+	values, err := csv.NewReader(strings.NewReader(raw)).Read()
+	if err != nil {
+		return cast.ToStringSlice(raw)
+	}
+
+	return values
+}
+
+// flagValue returns the value for the provided key as it would be applied to the flag
+func (v *Viperlet) flagValue(f *pflag.Flag, key string) string {
+	if _, ok := f.Value.(pflag.SliceValue); ok {
+		return strings.Join(v.sliceValue(key), ",")
+	}
+
+	value := v.rawValue(key)
+
+	// some types are normalised so values the flag cannot parse, but that have an obvious meaning, are applied correctly
+	switch f.Value.Type() {
+	case "duration":
+		// a plain integer, such as from a config file, is a number of nanoseconds
+		if d, err := cast.ToDurationE(value); err == nil {
+			return d.String()
+		}
+	case "count":
+		// a count flag may be used as a toggle so true is a count of one
+		if n, err := cast.ToIntE(value); err == nil {
+			return strconv.Itoa(n)
+		}
+	case "bool":
+		// any non-zero number is true
+		if b, err := cast.ToBoolE(value); err == nil {
+			return strconv.FormatBool(b)
+		}
+	}
+
+	return cast.ToString(value)
+}
+
+// ignored returns true if the flag was provided using [WithIgnoredFlags]
+func (v *Viperlet) ignored(f *pflag.Flag) bool {
+	return slices.Contains(v.ignoredFlags, f.Name)
+}
+
+// bindFlags binds each flag in the provided [*pflag.FlagSet] using the key returned by flagKey
+func (v *Viperlet) bindFlags(fs *pflag.FlagSet) error {
+	// without a prefix or ignored flags the flag name is used as-is
+	if v.flagKeyPrefix == "" && len(v.ignoredFlags) == 0 {
+		return v.Viper().BindPFlags(fs)
+	}
+
+	var err error
+	fs.VisitAll(func(f *pflag.Flag) {
+		if err != nil || v.ignored(f) {
+			return
+		}
+
+		err = v.Viper().BindPFlag(v.flagKey(f.Name), f)
+	})
+
+	return err
+}
+
+// unknownConfigKeys returns the sorted keys from the config that do not map to a flag in the provided flagsets, excluding the profiles and
+// the key used to select one when [WithProfile] is used
+func (v *Viperlet) unknownConfigKeys(flagset []*pflag.FlagSet) []string {
+	known := make(map[string]bool)
+	for _, fs := range flagset {
+		fs.VisitAll(func(f *pflag.Flag) {
+			if !v.ignored(f) {
+				known[strings.ToLower(v.flagKey(f.Name))] = true
+			}
+		})
+	}
+
+	var unknown []string
+	for _, key := range v.Viper().AllKeys() {
+		if known[key] || !v.Viper().InConfig(key) {
+			continue
+		}
+
+		if v.profileKey != "" && (key == strings.ToLower(v.profileKey) || strings.HasPrefix(key, "profiles"+v.keyDelim())) {
+			continue
+		}
+
+		unknown = append(unknown, key)
+	}
+	slices.Sort(unknown)
+
+	return unknown
+}
+
+// flagKey returns the key used in the underlying [*viper.Viper] instance for the named flag
+func (v *Viperlet) flagKey(name string) string {
+	if v.flagKeyPrefix == "" {
+		return name
+	}
+
+	return v.flagKeyPrefix + v.keyDelim() + name
+}
+
+// keyDelim returns the delimiter used for nested keys
+func (v *Viperlet) keyDelim() string {
+	if v.keyDelimiter != "" {
+		return v.keyDelimiter
+	}
+
+	return "."
+}
+
+// envConfig returns the env vars with the provided prefix as a nested map of config values. The env var names of known keys are mapped back to
+// the key, as the env key replacer means this cannot be done reliably from the name alone, otherwise the lowercased name is used as the key.
+func (v *Viperlet) envConfig(prefix string) map[string]any {
+	prefix = strings.ToUpper(prefix + "_")
+	replacer := v.envReplacer()
+
+	known := make(map[string]string)
+	for _, key := range v.Viper().AllKeys() {
+		name := strings.ToUpper(key)
+		if replacer != nil {
+			name = replacer.Replace(name)
+		}
+
+		known[name] = key
+	}
+
+	config := make(map[string]any)
+	for _, env := range os.Environ() {
+		name, value, _ := strings.Cut(env, "=")
+		if !strings.HasPrefix(name, prefix) || name == prefix {
+			continue
+		}
+
+		name = strings.TrimPrefix(name, prefix)
+		key, ok := known[name]
+		if !ok {
+			key = strings.ToLower(name)
+		}
+
+		setNested(config, strings.Split(key, v.keyDelim()), value)
+	}
+
+	return config
+}
+
+// setNested sets the value in the map under each part of the key in turn, creating nested maps as required
+func setNested(m map[string]any, parts []string, value any) {
+	for _, part := range parts[:len(parts)-1] {
+		next, ok := m[part].(map[string]any)
+		if !ok {
+			next = make(map[string]any)
+			m[part] = next
+		}
+
+		m = next
+	}
+
+	m[parts[len(parts)-1]] = value
+}
+
+// Unmarshal decodes the merged configuration into the provided struct, which is normally done after calling Init. If Init has not been called
+// the behaviour is the same as calling [viper.Unmarshal] on the underlying [*viper.Viper] instance.
+//
+// Keys are always case-insensitive and are lowercased by [viper], which has no option to preserve their case, so a case-sensitive option is
+// not provided. Decoding into a struct is not affected as field matching is case-insensitive, however when the original case is needed by
+// a downstream system the struct should be re-encoded using its own field names or tags rather than using [Viperlet.AllSettings].
+//
+// When [WithStrictUnmarshal] is used, an error is returned for any key that does not map to a field of the struct.
+func (v *Viperlet) Unmarshal(out any, opts ...viper.DecoderConfigOption) error {
+	if err := v.lazyLoad(); err != nil {
+		return err
+	}
+
+	if v.strictUnmarshal {
+		return v.Viper().UnmarshalExact(out, opts...)
+	}
+
+	return v.Viper().Unmarshal(out, opts...)
+}
+
+// ConfigFileUsed returns the path of the config file that was read by Init, which is useful to log when config files are searched for
+// using [WithConfigName]. When merging multiple config files this is the first config file. An empty string is returned if no config file
+// was read, such as when an optional config file was not found. See [viper.ConfigFileUsed] for details.
+func (v *Viperlet) ConfigFileUsed() string {
+	_ = v.lazyLoad()
+
+	if !v.configRead {
+		return ""
+	}
+
+	return v.Viper().ConfigFileUsed()
+}
+
+// Sub returns a new [Viperlet] with the same options, whose underlying [*viper.Viper] instance represents the subtree of the provided key,
+// which is useful for handing components only their own part of the config. Like [viper.Sub], nil is returned if the key does not exist.
+//
+// As options such as config files apply to the whole tree, the returned Viperlet is intended for looking up values and Init should not
+// be called on it.
+func (v *Viperlet) Sub(key string) *Viperlet {
+	// the sub-tree is taken from the loaded config, so the copy never loads it again
+	err := v.lazyLoad()
+
+	subv := v.Viper().Sub(key)
+	if subv == nil {
+		return nil
+	}
+
+	return &Viperlet{
+		viper:           subv,
+		configRead:      v.configRead,
+		initFlagsets:    v.initFlagsets,
+		applied:         v.applied,
+		lazyLoaded:      true,
+		lazyErr:         err,
+		lastConfig:      v.lastConfig,
+		envFilterRegexp: v.envFilterRegexp,
+		dotEnv:          v.dotEnv,
+		options:         v.options,
+	}
+}
+
+// AllSettings returns the merged settings from all sources, which is useful for debugging how values were resolved. This returns an empty
+// map if nothing has been set. See [viper.AllSettings] for details.
+func (v *Viperlet) AllSettings() map[string]any {
+	_ = v.lazyLoad()
+
+	return v.Viper().AllSettings()
+}
+
+// Snapshot returns a deep copy of the merged settings from all sources, which can later be passed to Restore to roll back changes, such as in
+// table-driven tests that tweak config.
+func (v *Viperlet) Snapshot() map[string]any {
+	_ = v.lazyLoad()
+
+	return deepCopy(v.Viper().AllSettings()).(map[string]any)
+}
+
+// Restore replaces the underlying [*viper.Viper] instance with a fresh instance holding a deep copy of the settings from a previous call to
+// Snapshot, so later changes to either the snapshot or the Viperlet do not affect each other. The restored settings are static values, so
+// any binding to flags or env vars done by Init is not restored.
+func (v *Viperlet) Restore(snapshot map[string]any) {
+	vp := v.newViper()
+	v.setViper(vp)
+	v.configRead = false
+
+	// the error is ignored as merging a map cannot fail
+	_ = vp.MergeConfigMap(deepCopy(snapshot).(map[string]any))
+}
+
+// deepCopy returns a copy of the value where any maps or slices, including those nested, are copied
+func deepCopy(value any) any {
+	switch value := value.(type) {
+	case map[string]any:
+		m := make(map[string]any, len(value))
+		for k, v := range value {
+			m[k] = deepCopy(v)
+		}
+
+		return m
+	case []any:
+		s := make([]any, len(value))
+		for i, v := range value {
+			s[i] = deepCopy(v)
+		}
+
+		return s
+	case []string:
+		return slices.Clone(value)
+	case []int:
+		return slices.Clone(value)
+	}
+
+	return value
+}
+
+// WriteConfigAs writes the effective configuration, including defaults and flag values, to the provided file, which is overwritten if it
+// already exists. The format is inferred from the file extension. See [viper.WriteConfigAs] for details.
+func (v *Viperlet) WriteConfigAs(filename string) error {
+	if err := v.lazyLoad(); err != nil {
+		return err
+	}
+
+	return v.Viper().WriteConfigAs(filename)
+}
+
+// AllKeys returns all keys that have a value from any source, including keys for bound flags, which together with AllSettings is useful
+// for introspection such as dumping the config. An empty slice is returned if nothing has been set. See [viper.AllKeys] for details.
+func (v *Viperlet) AllKeys() []string {
+	_ = v.lazyLoad()
+
+	return v.Viper().AllKeys()
+}
+
+// IsSet returns true if the key has been set from any source. See [viper.IsSet] for details.
+func (v *Viperlet) IsSet(key string) bool {
+	_ = v.lazyLoad()
+
+	return v.Viper().IsSet(key)
+}
+
+// GetString returns the value of the key as a string. See [viper.GetString] for details.
+func (v *Viperlet) GetString(key string) string {
+	_ = v.lazyLoad()
+
+	return v.Viper().GetString(key)
+}
+
+// GetInt returns the value of the key as an int. See [viper.GetInt] for details.
+func (v *Viperlet) GetInt(key string) int {
+	_ = v.lazyLoad()
+
+	return v.Viper().GetInt(key)
+}
+
+// GetBool returns the value of the key as a bool. See [viper.GetBool] for details.
+func (v *Viperlet) GetBool(key string) bool {
+	_ = v.lazyLoad()
+
+	return v.Viper().GetBool(key)
+}
+
+// GetStringSlice returns the value of the key as a slice of strings. See [viper.GetStringSlice] for details.
+func (v *Viperlet) GetStringSlice(key string) []string {
+	_ = v.lazyLoad()
+
+	return v.Viper().GetStringSlice(key)
+}
+
+// GetDuration returns the value of the key as a [time.Duration]. See [viper.GetDuration] for details.
+func (v *Viperlet) GetDuration(key string) time.Duration {
+	_ = v.lazyLoad()
+
+	return v.Viper().GetDuration(key)
+}
+
+// GetStringOr returns the value of the key as a string, or def if the key is not set, which complements [WithDefaults] for one-off reads.
+// The default value of a flag does not count as being set.
+func (v *Viperlet) GetStringOr(key, def string) string {
+	if !v.IsSet(key) {
+		return def
+	}
+
+	return v.GetString(key)
+}
+
+// GetIntOr returns the value of the key as an int, or def if the key is not set, as per [Viperlet.GetStringOr].
+func (v *Viperlet) GetIntOr(key string, def int) int {
+	if !v.IsSet(key) {
+		return def
+	}
+
+	return v.GetInt(key)
+}
+
+// GetBoolOr returns the value of the key as a bool, or def if the key is not set, as per [Viperlet.GetStringOr].
+func (v *Viperlet) GetBoolOr(key string, def bool) bool {
+	if !v.IsSet(key) {
+		return def
+	}
+
+	return v.GetBool(key)
+}
+
+// GetStringSliceOr returns the value of the key as a slice of strings, or def if the key is not set, as per [Viperlet.GetStringOr].
+func (v *Viperlet) GetStringSliceOr(key string, def []string) []string {
+	if !v.IsSet(key) {
+		return def
+	}
+
+	return v.GetStringSlice(key)
+}
+
+// GetDurationOr returns the value of the key as a [time.Duration], or def if the key is not set, as per [Viperlet.GetStringOr].
+func (v *Viperlet) GetDurationOr(key string, def time.Duration) time.Duration {
+	if !v.IsSet(key) {
+		return def
+	}
+
+	return v.GetDuration(key)
+}
+
+// Get returns the value for the provided key converted to type T, which supports the basic types and slices/maps handled by the typed getters
+// of [*viper.Viper] as well as structs, maps and slices that can be decoded from the value. If the key is not set the zero value of T is
+// returned without an error. An error wrapping [ErrTypeMismatch] is returned if the value cannot be converted to T, while an error wrapping
+// [ErrUnsupportedType] is returned if T is not supported at all.
+func Get[T any](v *Viperlet, key string) (T, error) {
+	var out T
+
+	if err := v.lazyLoad(); err != nil {
+		return out, err
+	}
+
+	value := v.Viper().Get(key)
+	if value == nil {
+		return out, nil
+	}
+
+	var (
+		result any
+		err    error
+	)
+
+	switch any(out).(type) {
+	case string:
+		result, err = cast.ToStringE(value)
+	case bool:
+		result, err = cast.ToBoolE(value)
+	case int:
+		result, err = cast.ToIntE(value)
+	case int32:
+		result, err = cast.ToInt32E(value)
+	case int64:
+		result, err = cast.ToInt64E(value)
+	case uint:
+		result, err = cast.ToUintE(value)
+	case uint32:
+		result, err = cast.ToUint32E(value)
+	case uint64:
+		result, err = cast.ToUint64E(value)
+	case float64:
+		result, err = cast.ToFloat64E(value)
+	case time.Time:
+		result, err = cast.ToTimeE(value)
+	case time.Duration:
+		result, err = cast.ToDurationE(value)
+	case []string:
+		result, err = cast.ToStringSliceE(value)
+	case []int:
+		result, err = cast.ToIntSliceE(value)
+	case map[string]any:
+		result, err = cast.ToStringMapE(value)
+	case map[string]string:
+		result, err = cast.ToStringMapStringE(value)
+	default:
+		// the value may already be the right type
+		if t, ok := value.(T); ok {
+			return t, nil
+		}
+
+		// otherwise attempt to decode into types that support it
+		switch reflect.TypeFor[T]().Kind() {
+		case reflect.Struct, reflect.Map, reflect.Slice:
+			if err := v.Viper().UnmarshalKey(key, &out); err != nil {
+				return out, fmt.Errorf("%w: %q: %w", ErrTypeMismatch, key, err)
+			}
+
+			return out, nil
+		}
+
+		return out, fmt.Errorf("%w: %T", ErrUnsupportedType, out)
+	}
+
+	if err != nil {
+		return out, fmt.Errorf("%w: %q: %w", ErrTypeMismatch, key, err)
+	}
+
+	return result.(T), nil
+}
+
+// isConfigNotFound returns true if the error indicates the config file could not be found, which covers a [viper.ConfigFileNotFoundError]
+// returned when searching for a config file and an [fs.ErrNotExist] (which [os.ErrNotExist] is equal to) when the config file is set directly.
+func isConfigNotFound(err error) bool {
+	var notFound viper.ConfigFileNotFoundError
+	if errors.As(err, &notFound) {
+		return true
+	}
+
+	return errors.Is(err, fs.ErrNotExist) || errors.Is(err, os.ErrNotExist)
+}
+
+// configReadError wraps an error from reading the named config file so it can be matched using [ErrReadConfig], along with [ErrConfigParse]
+// if the file could not be parsed or [os.ErrNotExist] if the file could not be found
+func configReadError(name string, err error) error {
+	var parseErr viper.ConfigParseError
+	switch {
+	case errors.As(err, &parseErr):
+		err = fmt.Errorf("%w: %w", ErrConfigParse, err)
+	case isConfigNotFound(err) && !errors.Is(err, os.ErrNotExist):
+		// a viper.ConfigFileNotFoundError does not wrap os.ErrNotExist
+		err = fmt.Errorf("%w: %w", os.ErrNotExist, err)
+	}
+
+	// config from an io.Reader has no name
+	if name == "" {
+		return fmt.Errorf("%w: %w", ErrReadConfig, err)
+	}
+
+	return fmt.Errorf("%w %q: %w", ErrReadConfig, name, err)
+}
+
+// remoteProvider is a remote config provider added using WithRemoteProvider or similar
+type remoteProvider struct {
+	provider      string
+	endpoint      string
+	path          string
+	secretKeyring string
+	optional      bool
+}
+
+// The Option is used to pass options to [New].
+type Option func(*Viperlet)
+
+// WithViper allows passing your own [*viper.Viper] instance
+func WithViper(viper *viper.Viper) Option {
+	return func(v *Viperlet) {
+		v.viper = viper
+	}
+}
+
+// WithEnv enables environment variable binding. See [viper.AutomaticEnv] for details.
+func WithEnv() Option {
+	return func(v *Viperlet) {
+		v.bindEnv = true
+	}
+}
+
+// WithEnvPrefix enables environment variable binding using the provided prefix. See [viper.SetEnvPrefix] for details.
+func WithEnvPrefix(prefix string) Option {
+	return func(v *Viperlet) {
+		v.used("WithEnvPrefix")
+		v.bindEnv = true
+		v.envPrefix = prefix
+	}
+}
+
+// WithEnvKeyReplacer uses the provided [*strings.Replacer] for environment variable names. See [viper.SetEnvKeyReplacer] for details.
+func WithEnvKeyReplacer(replacer *strings.Replacer) Option {
+	return func(v *Viperlet) {
+		v.used("WithEnvKeyReplacer")
+		v.bindEnv = true
+		v.envKeyReplacer = replacer
+	}
+}
+
+// WithConfig enables the reading of the provided config file. All errors, including if the config file is missing are treated as a failure.
+func WithConfig(config string) Option {
+	return func(v *Viperlet) {
+		v.used("WithConfig")
+		v.configFile = config
+		v.mergeConfigFiles = nil
+		v.allowMissingConfig = false
+	}
+}
+
+// WithOptionalConfig enables the reading of the provided config file however this differs from WithConfig as a missing config file is not fatal.
+// Only a missing config file is ignored, so a config file that exists but cannot be read or parsed is still treated as a failure.
+func WithOptionalConfig(config string) Option {
+	return func(v *Viperlet) {
+		v.used("WithOptionalConfig")
+		v.configFile = config
+		v.mergeConfigFiles = nil
+		v.allowMissingConfig = true
+	}
+}
+
+// WithConfigFileFromEnv sets the config file to the path in the named env var at the time Init is called, which is treated as an optional
+// config file as per [WithOptionalConfig]. The env var name is used as-is, so any prefix set using [WithEnvPrefix] is not applied.
+//
+// If the env var is set it takes precedence over any config file set using [WithConfig] or [WithOptionalConfig], otherwise this is a no-op.
+func WithConfigFileFromEnv(envName string) Option {
+	return func(v *Viperlet) {
+		v.configFileEnv = envName
+	}
+}
+
+// WithConfigFromEncodedEnv reads the config from the contents of the named env var at the time Init is called, which is decoded using the
+// provided encoding, such as when the whole config is passed as a single base64 env var by a CI system where mounting a file is not possible.
+// The env var name is used as-is, so any prefix set using [WithEnvPrefix] is not applied. As there is no file extension to infer the type
+// from, configType must be provided, unless a type is set using [WithConfigType], and it only applies to the config from the env var.
+//
+// If the env var is set it takes precedence over any config file set using [WithConfig] or similar, otherwise this is a no-op. Any error
+// decoding the config fails Init with an error wrapping [ErrReadConfig], as does config that is larger than the size set using
+// [WithMaxConfigSize] once decoded.
+func WithConfigFromEncodedEnv(envName, configType string, encoding Encoding) Option {
+	return func(v *Viperlet) {
+		v.used("WithConfigFromEncodedEnv")
+		v.configEncodedEnv = envName
+		v.configEncodedType = configType
+		v.configEncoding = encoding
+	}
+}
+
+// WithConfigBesideExecutable sets the config file to the file with the provided name, including the extension, in the same directory as the
+// running executable, which is treated as an optional config file as per [WithOptionalConfig]. This is useful for portable programs that are
+// distributed as a single binary. Any symlinks to the executable are resolved, so the directory is that of the actual binary.
+//
+// This is only used if no config file is set using [WithConfig], [WithOptionalConfig] or [WithConfigFileFromEnv].
+func WithConfigBesideExecutable(name string) Option {
+	return func(v *Viperlet) {
+		v.configExeName = name
+	}
+}
+
+// WithMaxConfigSize sets the maximum size in bytes of a config file, which is checked before the file is read, so Init returns an error
+// wrapping [ErrConfigTooLarge] rather than reading a huge file into memory. This applies to config files set by path, including those provided
+// using [WithMergeConfig] and [WithConfigFS], as well as config files found by searching using [WithConfigName] or [WithConfigNameAnyFormat].
+// A size of zero or less, which is the default, is unlimited.
+func WithMaxConfigSize(bytes int64) Option {
+	return func(v *Viperlet) {
+		v.maxConfigSize = bytes
+	}
+}
+
+// WithConfigType sets the type of the config file, which is required when the config file provided to [WithConfig] or [WithOptionalConfig]
+// has no extension. An empty type is ignored and the type is inferred from the file extension. See [viper.SetConfigType] for details.
+func WithConfigType(configType string) Option {
+	return func(v *Viperlet) {
+		v.configType = configType
+	}
+}
+
+// WithConfigFormat is the same as [WithConfigType] however it accepts a [ConfigFormat] rather than a string. An unknown format, including
+// [FormatUnknown], leaves the format to be inferred from the file extension.
+func WithConfigFormat(format ConfigFormat) Option {
+	return func(v *Viperlet) {
+		v.configType = format.String()
+	}
+}
+
+// WithConfigName enables searching for a config file with the provided name (without an extension) in the paths set using [WithConfigPaths].
+// All errors, including if the config file is not found in any of the paths are treated as a failure. See [viper.SetConfigName] for details.
+//
+// If a config file is also set using [WithConfig] or [WithOptionalConfig] then that file takes precedence and no search is performed.
+func WithConfigName(name string) Option {
+	return func(v *Viperlet) {
+		v.used("WithConfigName")
+		v.configName = name
+		v.allowMissingConfig = false
+	}
+}
+
+// WithOptionalConfigName is the same as [WithConfigName] however if the config file is not found in any of the search paths this is not fatal.
+func WithOptionalConfigName(name string) Option {
+	return func(v *Viperlet) {
+		v.used("WithOptionalConfigName")
+		v.configName = name
+		v.allowMissingConfig = true
+	}
+}
+
+// WithConfigNameAnyFormat enables searching for a config file with the provided name, without an extension, in the provided paths along with
+// any set using [WithConfigPaths], where the format is inferred from the extension of the first file found. The paths are searched in order
+// and within each path the extensions are tried in the order of [viper.SupportedExts], so for example "config.json" is found before
+// "config.yaml" in the same path. Any config type set using [WithConfigType] or [WithConfigFormat] is not used for the search, as it would
+// otherwise be used for whichever file is found. All errors, including if no config file is found in any of the paths, are treated as a failure.
+func WithConfigNameAnyFormat(name string, paths ...string) Option {
+	return func(v *Viperlet) {
+		v.used("WithConfigNameAnyFormat")
+		v.configName = name
+		v.configPaths = append(v.configPaths, paths...)
+		v.configAnyFormat = true
+		v.allowMissingConfig = false
+	}
+}
+
+// WithOptionalConfigNameAnyFormat is the same as [WithConfigNameAnyFormat] however if no config file is found in any of the paths this is not
+// fatal.
+func WithOptionalConfigNameAnyFormat(name string, paths ...string) Option {
+	return func(v *Viperlet) {
+		v.used("WithOptionalConfigNameAnyFormat")
+		v.configName = name
+		v.configPaths = append(v.configPaths, paths...)
+		v.configAnyFormat = true
+		v.allowMissingConfig = true
+	}
+}
+
+// WithConfigPaths adds the provided paths to the list of paths searched, in order, for the config file set using [WithConfigName]
+// or [WithOptionalConfigName]. See [viper.AddConfigPath] for details.
+func WithConfigPaths(paths ...string) Option {
+	return func(v *Viperlet) {
+		v.configPaths = append(v.configPaths, paths...)
+	}
+}
+
+// WithDefaults sets default values for the provided keys, which is useful for keys that do not have a corresponding flag. These defaults have
+// the lowest precedence so are overridden by env vars and config files, however they do take precedence over the default value of a flag.
+// Passing WithDefaults multiple times merges the provided maps. See [viper.SetDefault] for details.
+func WithDefaults(defaults map[string]any) Option {
+	return func(v *Viperlet) {
+		if v.defaults == nil {
+			v.defaults = make(map[string]any)
+		}
+
+		for key, value := range defaults {
+			v.defaults[key] = value
+		}
+	}
+}
+
+// WithOverrides sets values for the provided keys that take precedence over every other source, including flags set on the command line,
+// which is useful in tests to pin values regardless of env vars or config files. The values are set during Init using [viper.Set] and are
+// applied to flags as per any other value. Passing WithOverrides multiple times merges the provided maps.
+func WithOverrides(overrides map[string]any) Option {
 	return func(v *Viperlet) {
-		v.configFile = config
+		if v.overrides == nil {
+			v.overrides = make(map[string]any)
+		}
+
+		for key, value := range overrides {
+			v.overrides[strings.ToLower(key)] = value
+		}
+	}
+}
+
+// WithWatch enables watching the config file for changes once it has been read successfully during Init, so subsequent lookups via the
+// underlying [*viper.Viper] see the updated values. Each change reads the config again as per [Viperlet.Reload], including any files provided
+// using [WithMergeConfig], the active profile and transforms such as [WithValueDecryptor]. The optional onChange callback is run after each
+// change has been read. Flags are not updated unless [WithWatchReloadMode] is used.
+//
+// This only has an effect when a config file is set using [WithConfig], [WithOptionalConfig] or similar. Use [Viperlet.Close] to stop
+// watching once the Viperlet is no longer needed.
+func WithWatch(onChange func(fsnotify.Event)) Option {
+	return func(v *Viperlet) {
+		v.watchConfig = true
+		v.onConfigChange = onChange
+	}
+}
+
+// WithNonEmptyConfig makes Init return an error wrapping [ErrEmptyConfig] when a config file is found but contains no values, such as a
+// zero-byte file produced by a misconfigured volume mount. This applies to config from any source, such as [WithConfigFS] or
+// [WithConfigReader], while a missing optional config file is still ignored.
+func WithNonEmptyConfig() Option {
+	return func(v *Viperlet) {
+		v.nonEmptyConfig = true
+	}
+}
+
+// WithStringNormalizer sets a function, such as [strings.TrimSpace], that is applied to string values from any source before they are
+// applied to flags, which avoids subtle bugs such as a trailing newline in a mounted secret. This includes each element of a list applied
+// to a slice flag, however other values, such as numbers or booleans from a config file, are not affected. Values looked up using methods
+// such as [Viperlet.GetString] are not normalised.
+func WithStringNormalizer(fn func(string) string) Option {
+	return func(v *Viperlet) {
+		v.stringNormalizer = fn
+	}
+}
+
+// WithLazyConfig defers reading the config until a value is first looked up using a method such as [Viperlet.GetString], [Get] or
+// [Viperlet.Unmarshal], rather than during Init, which avoids the cost for commands that only use flags. Any error reading the config is
+// returned by the first method that returns an error, such as [Get] or [Viperlet.Unmarshal], or by [Viperlet.ConfigError] for getters
+// that do not return an error, which return values from the other sources if the config cannot be read.
+//
+// As the config is not read during Init, values from the config are not applied to flags, required keys and validators do not see values
+// from the config and env vars are not explicitly bound for keys that are only in the config, such as when using [WithEnvPrefixes]. Config
+// from a remote provider is still read during Init. The config is only read once, even when getters are called from multiple goroutines.
+func WithLazyConfig() Option {
+	return func(v *Viperlet) {
+		v.lazyConfig = true
+	}
+}
+
+// WithWatchReloadMode sets how values are applied to flags when the config file changes while using [WithWatch], which is run before any
+// onChange callback so it sees the updated flags. By default, [ReloadNone] is used so only the underlying [*viper.Viper] instance is updated.
+//
+// With [ReloadAll] the values from the changed config are applied to flags as per [Viperlet.Reload], which means a flag that was changed at
+// runtime is overwritten if its key is set in the config. With [ReloadChanged] the config file is compared to when it was last read and only
+// flags for keys that were added, removed or have a different value are applied, so runtime changes to other flags are preserved. Flags set
+// on the command line are never modified. Errors applying values are logged using the logger provided using [WithLogger], if any.
+func WithWatchReloadMode(mode ReloadMode) Option {
+	return func(v *Viperlet) {
+		v.watchReloadMode = mode
+	}
+}
+
+// WithFallbackConfig sets a config file that is read instead when the config file set using [WithConfig], [WithConfigName] or similar is not
+// found, which is useful for a "user config, else system default" pattern. Any other error reading the config file, such as it being
+// invalid, is still returned by Init rather than using the fallback. If the fallback is also not found, the error for a missing config
+// file is returned unless an optional config file is used. Any files provided using [WithMergeConfig] are merged over the fallback.
+func WithFallbackConfig(path string) Option {
+	return func(v *Viperlet) {
+		v.fallbackConfigFile = path
+	}
+}
+
+// WithMergeConfig enables the reading of multiple config files, where the first file is read as per [WithConfig] and the remaining files are
+// merged in order, so values in later files take precedence over earlier ones. All errors, including if any config file is missing are
+// treated as a failure. See [viper.MergeInConfig] for details.
+//
+// When combined with [WithWatch] only the first config file is watched, however every file is read and merged again when it changes.
+func WithMergeConfig(paths ...string) Option {
+	return func(v *Viperlet) {
+		v.used("WithMergeConfig")
+		if len(paths) > 0 {
+			v.configFile = paths[0]
+			v.mergeConfigFiles = paths[1:]
+		}
+		v.allowMissingConfig = false
+	}
+}
+
+// WithOptionalMergeConfig is the same as [WithMergeConfig] however any missing config files are skipped rather than being fatal.
+func WithOptionalMergeConfig(paths ...string) Option {
+	return func(v *Viperlet) {
+		v.used("WithOptionalMergeConfig")
+		if len(paths) > 0 {
+			v.configFile = paths[0]
+			v.mergeConfigFiles = paths[1:]
+		}
+		v.allowMissingConfig = true
+	}
+}
+
+// WithConfigFS enables reading the named config file from the provided [fs.FS], such as an [embed.FS] containing default config that is
+// shipped inside the binary, with the type inferred from the extension unless set using [WithConfigType]. All errors, including if the file
+// is missing are treated as a failure. Any config file set using [WithConfig] or similar is ignored, as are any files provided using
+// [WithMergeConfig], and the config cannot be watched for changes.
+func WithConfigFS(fsys fs.FS, name string) Option {
+	return func(v *Viperlet) {
+		v.used("WithConfigFS")
+		v.configFS = fsys
+		v.configFSName = name
+		v.allowMissingConfig = false
+	}
+}
+
+// WithOptionalConfigFS is the same as [WithConfigFS] however if the file is missing from the filesystem this is not fatal.
+func WithOptionalConfigFS(fsys fs.FS, name string) Option {
+	return func(v *Viperlet) {
+		v.used("WithOptionalConfigFS")
+		v.configFS = fsys
+		v.configFSName = name
+		v.allowMissingConfig = true
+	}
+}
+
+// WithConfigURL enables fetching the config from the provided HTTP or HTTPS URL during Init, which avoids staging config from an internal
+// endpoint as a local file. If configType is empty, the type set using [WithConfigType] is used, otherwise it is inferred from the
+// extension of the URL path. Any response other than a 200 OK is treated as a failure, where a 404 Not Found or 410 Gone response returns
+// an error that matches [os.ErrNotExist]. Any config file set using [WithConfig] or similar is ignored, as are any files provided using
+// [WithMergeConfig], and the config cannot be watched for changes, however it is fetched again by [Viperlet.Reload].
+//
+// The request uses the context passed to InitContext and the client provided using [WithHTTPClient], or [http.DefaultClient] by default,
+// which has no timeout.
+func WithConfigURL(url, configType string) Option {
+	return func(v *Viperlet) {
+		v.used("WithConfigURL")
+		v.configURL = url
+		v.configURLType = configType
+		v.allowMissingConfig = false
+	}
+}
+
+// WithOptionalConfigURL is the same as [WithConfigURL] however a 404 Not Found or 410 Gone response is not fatal. Any other failure, such
+// as another response status or config that cannot be parsed, is still returned by Init.
+func WithOptionalConfigURL(url, configType string) Option {
+	return func(v *Viperlet) {
+		v.used("WithOptionalConfigURL")
+		v.configURL = url
+		v.configURLType = configType
 		v.allowMissingConfig = true
 	}
 }
+
+// WithHTTPClient sets the [*http.Client] used to fetch config provided using [WithConfigURL] or [WithOptionalConfigURL], which allows the
+// timeout, transport or authentication to be customised.
+func WithHTTPClient(client *http.Client) Option {
+	return func(v *Viperlet) {
+		v.httpClient = client
+	}
+}
+
+// WithTimeout bounds the time taken to read the config during Init, or when it is read again by [Viperlet.Reload] or on first use when using
+// [WithLazyConfig], so Init does not hang with a slow or unresponsive source. Once the timeout has passed the read fails with an error wrapping
+// [ErrReadConfig] and [context.DeadlineExceeded]. Any deadline of the context passed to InitContext still applies too.
+//
+// The timeout applies to fetching config provided using [WithConfigURL] and reading from remote providers, while for config files it guards
+// checking that the file can be accessed, as reading a local file cannot be cancelled, so only files set using [WithConfig] or similar are
+// covered rather than those found by searching using [WithConfigName]. When reading from remote providers times out, the underlying
+// [*viper.Viper] instance is replaced as per [Viperlet.Reset], so the abandoned read cannot modify it.
+func WithTimeout(d time.Duration) Option {
+	return func(v *Viperlet) {
+		v.configTimeout = d
+	}
+}
+
+// WithRemoteProvider enables reading config from a remote key/value store, such as Consul or etcd, at the provided path. The provider must be
+// one of [viper.SupportedRemoteProviders] and the remote features of viper must be enabled by a blank import of "github.com/spf13/viper/remote"
+// by the program, so this package does not depend on the client libraries for every provider. The config type must be set using
+// [WithConfigType] or [WithConfigFormat] unless a config file with an extension is also used. See [viper.AddRemoteProvider] for details.
+//
+// This may be used multiple times, in which case the config is read from the first provider that succeeds. Remote config is in addition
+// to any config file and has a lower precedence, so values from a config file take precedence over remote values. Any failure to read the
+// remote config is treated as a failure.
+func WithRemoteProvider(provider, endpoint, path string) Option {
+	return func(v *Viperlet) {
+		v.remoteProviders = append(v.remoteProviders, remoteProvider{provider: provider, endpoint: endpoint, path: path})
+	}
+}
+
+// WithOptionalRemoteProvider is the same as [WithRemoteProvider] however a failure to read the remote config is not fatal, unless another
+// provider was added using [WithRemoteProvider] or [WithRemoteProviderSecure]. As viper does not distinguish between missing config and
+// other failures, such as the remote store being unavailable, any failure to read is ignored.
+func WithOptionalRemoteProvider(provider, endpoint, path string) Option {
+	return func(v *Viperlet) {
+		v.remoteProviders = append(v.remoteProviders, remoteProvider{provider: provider, endpoint: endpoint, path: path, optional: true})
+	}
+}
+
+// WithRemoteProviderSecure is the same as [WithRemoteProvider] however the values are encrypted, and are decrypted using the provided secret
+// keyring. See [viper.AddSecureRemoteProvider] for details.
+func WithRemoteProviderSecure(provider, endpoint, path, secretKeyring string) Option {
+	return func(v *Viperlet) {
+		v.remoteProviders = append(v.remoteProviders, remoteProvider{provider: provider, endpoint: endpoint, path: path, secretKeyring: secretKeyring})
+	}
+}
+
+// WithOptionalRemoteProviderSecure is the same as [WithRemoteProviderSecure] however failure to read the remote config is not fatal, as per
+// [WithOptionalRemoteProvider].
+func WithOptionalRemoteProviderSecure(provider, endpoint, path, secretKeyring string) Option {
+	return func(v *Viperlet) {
+		v.remoteProviders = append(v.remoteProviders, remoteProvider{provider: provider, endpoint: endpoint, path: path, secretKeyring: secretKeyring, optional: true})
+	}
+}
+
+// WithConfigReader enables reading the config from the provided [io.Reader] using the provided config type, which avoids writing config that
+// is already in memory to disk. If configType is empty, the type set using [WithConfigType] is used. Any config file set using [WithConfig]
+// or similar is ignored. See [viper.ReadConfig] for details.
+func WithConfigReader(r io.Reader, configType string) Option {
+	return func(v *Viperlet) {
+		v.used("WithConfigReader")
+		v.configReader = r
+		v.configReaderType = configType
+	}
+}
+
+// WithEnvVars enables environment variable binding for only the provided keys rather than all keys, which still honours any prefix set using
+// [WithEnvPrefix] and replacer set using [WithEnvKeyReplacer]. If combined with [WithEnv], only the provided keys are bound.
+// See [viper.BindEnv] for details.
+func WithEnvVars(keys ...string) Option {
+	return func(v *Viperlet) {
+		v.bindEnv = true
+		v.envVars = append(v.envVars, keys...)
+	}
+}
+
+// WithFlagSet provides a [*pflag.FlagSet] at construction time, so it is bound when Init is called without having to pass it again.
+// This may be passed multiple times and any flagsets passed to Init are bound as well. Passing a nil flagset results in Init returning
+// [ErrInvalidFlagset].
+func WithFlagSet(fs *pflag.FlagSet) Option {
+	return func(v *Viperlet) {
+		v.flagsets = append(v.flagsets, fs)
+	}
+}
+
+// WithValidate adds a validation function that is run by Init once all flags, env vars and config have been merged, but before any values
+// are applied to flags, with any error returned by Init. This may be passed multiple times, where the validation functions are run in order
+// and the first error is returned.
+func WithValidate(fn func(*viper.Viper) error) Option {
+	return func(v *Viperlet) {
+		v.validators = append(v.validators, fn)
+	}
+}
+
+// WithWriteConfigOnInit writes the effective configuration to the provided file at the end of Init, which is useful to generate a starter
+// config file. See [Viperlet.WriteConfigAs] for details.
+func WithWriteConfigOnInit(path string) Option {
+	return func(v *Viperlet) {
+		v.writeConfigFile = path
+	}
+}
+
+// WithStrict makes Init return [ErrNothingToBind] when there is no flagset, env binding is not enabled and no config is set, rather than
+// silently doing nothing.
+func WithStrict() Option {
+	return func(v *Viperlet) {
+		v.strict = true
+	}
+}
+
+// WithEnvKeyReplacerDefault enables environment variable binding with a replacer that maps both "." and "-" to "_", so a key such as
+// "server.listen-address" is looked up as SERVER_LISTEN_ADDRESS. A replacer provided using [WithEnvKeyReplacer] takes precedence regardless
+// of the order the options are passed.
+func WithEnvKeyReplacerDefault() Option {
+	return func(v *Viperlet) {
+		v.used("WithEnvKeyReplacerDefault")
+		v.bindEnv = true
+		v.defaultEnvKeyReplacer = true
+	}
+}
+
+// WithIgnoredFlags excludes the flags with the provided names from being bound or having values applied, so flags such as "help" or
+// "version" are never set from an env var or config file. This may be used multiple times.
+func WithIgnoredFlags(names ...string) Option {
+	return func(v *Viperlet) {
+		v.ignoredFlags = append(v.ignoredFlags, names...)
+	}
+}
+
+// WithFlagKeyPrefix binds flags under the provided prefix, so the flag "--timeout" with a prefix of "db" uses the key "db.timeout". This
+// allows flags for different components to share a [*viper.Viper] instance without collisions.
+//
+// As the prefix is part of the key, it is also part of the environment variable name, so [WithEnvKeyReplacer] or [WithEnvKeyReplacerDefault]
+// should be used so that "db.timeout" is looked up as DB_TIMEOUT.
+func WithFlagKeyPrefix(prefix string) Option {
+	return func(v *Viperlet) {
+		v.flagKeyPrefix = prefix
+	}
+}
+
+// WithEnvPrefixes enables environment variable binding where each key is looked up using each of the provided prefixes, which is useful when
+// migrating from one prefix to another. The first matching env var wins in the order the prefixes are given. This replaces any prefix set
+// using [WithEnvPrefix] and as [viper.AutomaticEnv] only supports a single prefix, every known key, or only those provided using
+// [WithEnvVars], is explicitly bound during Init. See [viper.BindEnv] for details.
+func WithEnvPrefixes(prefixes ...string) Option {
+	return func(v *Viperlet) {
+		v.used("WithEnvPrefixes")
+		v.bindEnv = true
+		v.envPrefixes = append(v.envPrefixes, prefixes...)
+	}
+}
+
+// WithEnvPrefixSeparator sets the separator used to join the env var prefix to the key, which is "_" by default, so a separator such as "__"
+// can distinguish the prefix from nested keys, meaning "MYAPP__DB_HOST" is the env var for "db.host" with a prefix of "myapp" when used with
+// [WithEnvKeyReplacerDefault]. This applies to the prefixes set using [WithEnvPrefix] or [WithEnvPrefixes] and has no effect without one.
+//
+// The env key replacer is applied to the whole env var name, including the prefix and separator, so the separator should not contain any
+// characters the replacer changes. As [viper.AutomaticEnv] always joins the prefix using an "_", when another separator is used every known
+// key, or only those provided using [WithEnvVars], is explicitly bound during Init, as per [WithEnvPrefixes].
+func WithEnvPrefixSeparator(sep string) Option {
+	return func(v *Viperlet) {
+		v.envPrefixSeparator = sep
+	}
+}
+
+// WithEnvFilter enables environment variable binding for only the env vars with a name matching the provided regular expression, such as
+// "^MYAPP_.*", which is more flexible than a fixed prefix as the rest of the env vars are ignored, even when the name matches a key. Env vars
+// that match but are not a known key are bound to the lowercased name without any prefix set using [WithEnvPrefix] or [WithEnvPrefixes], so
+// "MYAPP_COLOUR" sets the "colour" key with a prefix of "myapp", or the "myapp_colour" key without one.
+//
+// The env var name for each key is worked out as usual, including any prefix and the env key replacer, before it is matched against the
+// pattern. Env vars provided using [WithFlagEnv] are not filtered. As [viper.AutomaticEnv] cannot filter env vars, every known key, or only
+// those provided using [WithEnvVars], is explicitly bound during Init, as per [WithEnvPrefixes]. Init returns an error wrapping
+// [ErrInvalidEnvFilter] if the pattern is not a valid regular expression.
+func WithEnvFilter(pattern string) Option {
+	return func(v *Viperlet) {
+		v.bindEnv = true
+		v.envFilter = pattern
+	}
+}
+
+// WithEnvLookup sets the function used to look up the value of env vars in place of [os.LookupEnv], which allows tests to provide env vars
+// from a map without modifying the environment of the process. This applies to env vars bound to keys, the env var provided using
+// [WithConfigFileFromEnv] and references expanded using [WithEnvExpansion], however the names of env vars are still found by listing the
+// environment when using [WithEnvAsConfig], [WithEnvFilter] or [WithEnvCaseInsensitive]. A dotenv file provided using [WithDotEnv] or
+// [WithOptionalDotEnv] is not loaded into the environment, instead its values are used for env vars that the function does not return.
+//
+// As the underlying [*viper.Viper] instance always reads the environment of the process, env vars are not bound to it when a function is
+// provided. Instead, the value of the env var for each known key is set during Init as per [viper.Set], so keys that are bound afterwards,
+// such as by using [Viperlet.BindEnv], do not use the function.
+func WithEnvLookup(fn func(string) (string, bool)) Option {
+	return func(v *Viperlet) {
+		v.envLookup = fn
+	}
+}
+
+// WithDotEnv enables environment variable binding and loads the provided dotenv formatted file into the environment during Init, so its
+// values are subject to the same prefix and replacer rules as any other env var. Env vars that are already set are not overridden by
+// values from the file. All errors, including if the file is missing are treated as a failure.
+func WithDotEnv(path string) Option {
+	return func(v *Viperlet) {
+		v.bindEnv = true
+		v.dotEnvFile = path
+		v.allowMissingDotEnv = false
+	}
+}
+
+// WithOptionalDotEnv is the same as [WithDotEnv] however a missing dotenv file is not fatal.
+func WithOptionalDotEnv(path string) Option {
+	return func(v *Viperlet) {
+		v.bindEnv = true
+		v.dotEnvFile = path
+		v.allowMissingDotEnv = true
+	}
+}
+
+// WithLogger logs the final value of each flag at the end of Init, along with a best-effort attribution of where the value came from, which
+// is one of "flag", "env", "config", "default" or "override". All messages are logged at the debug level.
+func WithLogger(l *slog.Logger) Option {
+	return func(v *Viperlet) {
+		v.logger = l
+	}
+}
+
+// WithKeyDelimiter sets the delimiter used for nested keys instead of the default of ".", which is useful when keys legitimately contain dots.
+// As the delimiter cannot be changed once the underlying [*viper.Viper] instance is created, this has no effect when combined with
+// [WithViper]. See [viper.KeyDelimiter] for details.
+func WithKeyDelimiter(delim string) Option {
+	return func(v *Viperlet) {
+		v.keyDelimiter = delim
+	}
+}
+
+// WithEnvTransform runs the provided function for each value that is sourced from an env var, with the result replacing the value in the
+// underlying [*viper.Viper] instance, which allows values such as base64 encoded secrets or comma-separated lists to be processed in one
+// place. Any error returned by the function is returned by Init. This only applies to keys that are known once any config has been read.
+//
+// As the transformed value is set using [viper.Set], it takes precedence over all other sources except for flags set on the command line,
+// unless [WithEnvOverride] is also used.
+func WithEnvTransform(fn func(key, raw string) (any, error)) Option {
+	return func(v *Viperlet) {
+		v.envTransform = fn
+	}
+}
+
+// WithRequired makes Init return an error wrapping [ErrMissingRequired] that lists every one of the provided keys that is not set once all
+// flags, env vars and config have been merged. Unlike marking a flag as required, the value may come from any source, however the default
+// value of a flag does not count as being set. This may be passed multiple times.
+func WithRequired(keys ...string) Option {
+	return func(v *Viperlet) {
+		v.required = append(v.required, keys...)
+	}
+}
+
+// WithEnvCaseInsensitive makes env var lookups ignore case, so "CMD_EXAMPLE", "cmd_example" and "Cmd_Example" all set the "example" key,
+// which is useful when env vars are not exported in uppercase. The env var name for a key is worked out as usual, including any prefix set
+// using [WithEnvPrefix] or [WithEnvPrefixes] and the env key replacer, and is then compared to the names of the env vars that are set,
+// ignoring case. When more than one matches, the uppercase name takes precedence.
+//
+// As the names of env vars are only compared when Init is called, env vars set afterwards are only found if the name is uppercase.
+func WithEnvCaseInsensitive() Option {
+	return func(v *Viperlet) {
+		v.bindEnv = true
+		v.envCaseInsensitive = true
+	}
+}
+
+// WithFlagEnv binds the flag with the provided name to the provided env vars, in order of precedence, which is useful when the env var name
+// cannot be derived from the flag name, such as using "DATABASE_URL" for a flag named "db-url". The env var names are used as-is, so any
+// prefix set using [WithEnvPrefix] is not applied, and this does not enable env binding for any other flags. This may be used multiple times
+// for different flags.
+//
+// When env binding is also enabled, such as by using [WithEnv], the env var name derived from the flag name is still bound and takes
+// precedence if it is set, as the underlying [*viper.Viper] instance checks automatic env vars before explicitly bound ones.
+func WithFlagEnv(flagName string, envVars ...string) Option {
+	return func(v *Viperlet) {
+		if v.flagEnvs == nil {
+			v.flagEnvs = make(map[string][]string)
+		}
+
+		v.flagEnvs[flagName] = envVars
+	}
+}
+
+// WithoutFlagPropagation stops Init from applying values to flags, so flags are bound to the underlying [*viper.Viper] instance and values
+// are resolved as usual, however the flags only hold values from the command line or their defaults, which is useful when values are read
+// using [Viperlet.Unmarshal] or the Get methods rather than from the flag variables.
+//
+// As no values are applied, [pflag.Flag.Changed] is only true for flags set on the command line, and neither the logger provided using
+// [WithLogger] nor the hook provided using [WithFlagApplyHook] is called for any flags.
+func WithoutFlagPropagation() Option {
+	return func(v *Viperlet) {
+		v.noPropagation = true
+	}
+}
+
+// WithFlagApplyHook sets a function that is called by Init for each flag once any value has been applied, which is useful for auditing or
+// validating values in one place. The function is called with the flag, the value of the flag and the source of the value, which is one of
+// "flag", "env", "config", "default" or "override" as per [Source]. Returning an error stops any further flags being modified, restores any
+// flags already modified to their previous value and Init returns the error.
+func WithFlagApplyHook(fn func(f *pflag.Flag, value string, source string) error) Option {
+	return func(v *Viperlet) {
+		v.flagApplyHook = fn
+	}
+}
+
+// WithEnvOverride reverses the normal precedence of flags and env vars, so that when a flag is set on the command line and the env var
+// bound to it is also set, the env var wins. This is the opposite of the precedence used by [viper] and is intended for cases such as
+// containers where flags baked into an entrypoint need to be overridden by the environment.
+//
+// This only has an effect when env var binding is enabled, with the env var value being set using [viper.Set] so it is also returned by
+// the underlying [*viper.Viper] instance.
+func WithEnvOverride() Option {
+	return func(v *Viperlet) {
+		v.envOverride = true
+	}
+}
+
+// WithAlias registers alias as an alternative name for key, so a value under the alias in a config file is used for the key, which is useful
+// when a config key has been renamed. Aliases are registered during Init after config is read but before values are applied to flags, so a
+// value set using the alias reaches the flag for the key. This may be passed multiple times. See [viper.RegisterAlias] for details.
+func WithAlias(alias, key string) Option {
+	return func(v *Viperlet) {
+		if v.aliases == nil {
+			v.aliases = make(map[string]string)
+		}
+
+		v.aliases[alias] = key
+	}
+}
+
+// WithProfile selects an active profile using the value of the provided key, which may come from a flag, env var or the config itself, and
+// merges the values under "profiles.<name>" in the config over the top-level values during Init, so a single config file can hold the
+// settings for several environments, such as:
+//
+//	listen: :8080
+//	profiles:
+//	  dev:
+//	    listen: localhost:8080
+//	  prod:
+//	    listen: :80
+//
+// Profile values take precedence over top-level values in the config, but not over flags set on the command line, env vars or values merged
+// using [WithEnvAsConfig]. Nothing is merged if the key is not set or is empty, however if the selected profile is not in the config then Init
+// returns an error wrapping [ErrUnknownProfile]. The profile is merged again by [Viperlet.Reload].
+func WithProfile(key string) Option {
+	return func(v *Viperlet) {
+		v.profileKey = key
+	}
+}
+
+// WithEnvAsConfig merges all env vars starting with the provided prefix, followed by an "_", into the config with the prefix removed, so the
+// values are first-class config entries, which differs from [WithEnv] as keys that are not otherwise known are included by [Viperlet.Unmarshal]
+// and [Viperlet.AllSettings]. An empty prefix is ignored.
+//
+// The env var name is mapped back to a key using the env key replacer for keys that are known from flags, defaults or config files, otherwise
+// the lowercased name is used as the key, so "MYAPP_DB_HOST" becomes "db_host" unless "db.host" is a known key.
+//
+// The values are merged after any config files are read, so take precedence over values from config files and defaults, however flags set on
+// the command line still take precedence when values are applied to flags. Values are merged again whenever the config is re-read, including
+// by [Viperlet.Reload] and on changes when using [WithWatch].
+func WithEnvAsConfig(prefix string) Option {
+	return func(v *Viperlet) {
+		v.envAsConfigPrefix = prefix
+	}
+}
+
+// WithValueDecryptor sets a function to decrypt values from config files, such as secrets that are encrypted using SOPS or age, which is run
+// once config is read and before values are applied to flags. The function is called with every string value from config files, so it should
+// check for a marker such as an "ENC[" prefix and return the raw value unchanged if the value is not encrypted. Values set using a flag on
+// the command line or an env var are not passed to the function. Any error returned fails Init.
+func WithValueDecryptor(fn func(key string, raw []byte) ([]byte, error)) Option {
+	return func(v *Viperlet) {
+		v.valueDecryptor = fn
+	}
+}
+
+// WithEnvBindingErrorsFatal controls whether errors binding keys to env vars, such as an empty key passed to [WithEnvVars], cause Init to fail
+// with an error wrapping [ErrBindEnv], which is the default. Passing false makes binding lenient, so keys that cannot be bound are skipped
+// and the errors are logged using the logger provided using [WithLogger], if any.
+func WithEnvBindingErrorsFatal(fatal bool) Option {
+	return func(v *Viperlet) {
+		v.lenientEnvBinding = !fatal
+	}
+}
+
+// WithEnvExpansion replaces references to env vars, in the form ${VAR} or $VAR, in string values from config files with the value of the env
+// var, which is done once config is read and before values are applied to flags. References to env vars that are not set are replaced with
+// an empty string, unless [WithStrictEnvExpansion] is used. Values set using a flag on the command line or an env var are not expanded.
+// See [os.Expand] for details.
+func WithEnvExpansion() Option {
+	return func(v *Viperlet) {
+		v.envExpansion = true
+	}
+}
+
+// WithStrictEnvExpansion is the same as [WithEnvExpansion] however Init returns an error wrapping [ErrUndefinedEnvVar] if a config value
+// references an env var that is not set.
+func WithStrictEnvExpansion() Option {
+	return func(v *Viperlet) {
+		v.envExpansion = true
+		v.strictEnvExpansion = true
+	}
+}
+
+// WithExplicitEnv binds only the provided keys to env vars and never enables [viper.AutomaticEnv], so env var usage is deterministic and
+// stray env vars that happen to match a key are never used. The prefix set using [WithEnvPrefix] is still honoured.
+//
+// This is equivalent to [WithEnvVars], as binding specific keys always replaces automatic binding.
+func WithExplicitEnv(keys ...string) Option {
+	return WithEnvVars(keys...)
+}
+
+// WithUnknownKeyHandler sets a function that Init calls, once values have been applied to flags, for each key from the config that does not
+// map to a flag, in sorted order, which is useful to warn about misspelled or deprecated keys without failing as [WithStrictUnmarshal] does.
+// Keys are those from config files and any other config source, including values merged using [WithEnvAsConfig], while flags provided using
+// [WithIgnoredFlags] are not considered to be bound. When [WithProfile] is used, the profiles and the key used to select one are excluded.
+//
+// As a struct is not known to Init, keys that only map to a field of a struct passed to [Viperlet.Unmarshal] are reported too. The config is
+// not checked when using [WithLazyConfig], as it has not been read when Init returns.
+func WithUnknownKeyHandler(fn func(key string)) Option {
+	return func(v *Viperlet) {
+		v.unknownKeyHandler = fn
+	}
+}
+
+// WithStrictUnmarshal makes [Viperlet.Unmarshal] return an error for any key that does not map to a field of the struct, which catches
+// misspelled keys in config files. As all keys are checked, this includes keys for bound flags, so every flag needs a matching field.
+// See [viper.UnmarshalExact] for details.
+func WithStrictUnmarshal() Option {
+	return func(v *Viperlet) {
+		v.strictUnmarshal = true
+	}
+}
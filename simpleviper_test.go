@@ -0,0 +1,2117 @@
+package simpleviper
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"maps"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/spf13/pflag"
+	"github.com/spf13/viper"
+)
+
+func TestIsConfigNotFound(t *testing.T) {
+	_, pathErr := os.Open("testdata/missing.yml")
+
+	// search for a config file that does not exist
+	v := viper.New()
+	v.SetConfigName("missing")
+	v.AddConfigPath("testdata")
+	searchErr := v.ReadInConfig()
+
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"viper not found", viper.ConfigFileNotFoundError{}, true},
+		{"viper search not found", searchErr, true},
+		{"wrapped viper not found", fmt.Errorf("wrapped: %w", viper.ConfigFileNotFoundError{}), true},
+		{"os not exist", os.ErrNotExist, true},
+		{"fs not exist", fs.ErrNotExist, true},
+		{"path error", pathErr, true},
+		{"wrapped path error", configReadError("testdata/missing.yml", pathErr), true},
+		{"unsupported config", viper.UnsupportedConfigError("txt"), false},
+		{"other error", errors.New("other error"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := isConfigNotFound(tt.err); got != tt.want {
+				t.Errorf("isConfigNotFound() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInitOptionalConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		wantErr bool
+	}{
+		{"missing optional config", []Option{WithOptionalConfig("testdata/missing.yml")}, false},
+		{"invalid optional config", []Option{WithOptionalConfig("testdata/invalid.yml")}, true},
+		{"missing optional config name", []Option{WithOptionalConfigName("missing"), WithConfigPaths("testdata")}, false},
+		{"invalid optional config name", []Option{WithOptionalConfigName("invalid"), WithConfigPaths("testdata")}, true},
+		{"missing optional merge config", []Option{WithOptionalMergeConfig("testdata/base.yml", "testdata/missing.yml")}, false},
+		{"invalid optional merge config", []Option{WithOptionalMergeConfig("testdata/base.yml", "testdata/invalid.yml")}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := New(tt.opts...).Init()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Init() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr && isConfigNotFound(err) {
+				t.Errorf("Init() error = %v, should not be a not found error", err)
+			}
+		})
+	}
+}
+
+func TestInitConfigErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		wantErr error
+	}{
+		{"missing config", []Option{WithConfig("testdata/missing.yml")}, os.ErrNotExist},
+		{"missing config name", []Option{WithConfigName("missing"), WithConfigPaths("testdata")}, os.ErrNotExist},
+		{"invalid config", []Option{WithConfig("testdata/invalid.yml")}, ErrConfigParse},
+		{"invalid optional config", []Option{WithOptionalConfig("testdata/invalid.yml")}, ErrConfigParse},
+		{"invalid config name", []Option{WithConfigName("invalid"), WithConfigPaths("testdata")}, ErrConfigParse},
+		{"invalid merge config", []Option{WithMergeConfig("testdata/base.yml", "testdata/invalid.yml")}, ErrConfigParse},
+		{"invalid config reader", []Option{WithConfigReader(strings.NewReader("example: [unclosed"), "yaml")}, ErrConfigParse},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := New(tt.opts...).Init()
+			if !errors.Is(err, ErrReadConfig) {
+				t.Errorf("Init() error = %v, want %v", err, ErrReadConfig)
+			}
+
+			if !errors.Is(err, tt.wantErr) {
+				t.Errorf("Init() error = %v, want %v", err, tt.wantErr)
+			}
+
+			// not found and parse errors are distinct
+			if errors.Is(err, ErrConfigParse) && errors.Is(err, os.ErrNotExist) {
+				t.Errorf("Init() error = %v, should not be both a parse and not found error", err)
+			}
+		})
+	}
+}
+
+func TestInitNilFlagset(t *testing.T) {
+	var example string
+
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringVar(&example, "example4", "", "Example flag")
+	fs.Parse([]string{})
+
+	err := New(WithConfig("example.yml")).Init(fs, nil)
+	if !errors.Is(err, ErrInvalidFlagset) {
+		t.Errorf("Init() error = %v, want %v", err, ErrInvalidFlagset)
+	}
+
+	if example != "" {
+		t.Errorf("example = %q, want flag to be untouched", example)
+	}
+}
+
+func TestInitConfigFS(t *testing.T) {
+	fsys := fstest.MapFS{
+		"config.yml":  {Data: []byte("example: from config file\n")},
+		"config":      {Data: []byte("example: from config file without extension\n")},
+		"invalid.yml": {Data: []byte("example: [\n")},
+	}
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		want    string
+		wantErr bool
+	}{
+		{"config", []Option{WithConfigFS(fsys, "config.yml")}, "from config file", false},
+		{"config with type", []Option{WithConfigFS(fsys, "config"), WithConfigType("yaml")}, "from config file without extension", false},
+		{"missing config", []Option{WithConfigFS(fsys, "missing.yml")}, "", true},
+		{"missing optional config", []Option{WithOptionalConfigFS(fsys, "missing.yml")}, "", false},
+		{"invalid optional config", []Option{WithOptionalConfigFS(fsys, "invalid.yml")}, "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New(tt.opts...)
+			err := v.Init()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Init() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				if !errors.Is(err, ErrReadConfig) {
+					t.Errorf("Init() error = %v, want %v", err, ErrReadConfig)
+				}
+
+				return
+			}
+
+			if got := v.GetString("example"); got != tt.want {
+				t.Errorf("GetString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithFlagSetNil(t *testing.T) {
+	err := New(WithFlagSet(nil)).Init()
+	if !errors.Is(err, ErrInvalidFlagset) {
+		t.Errorf("Init() error = %v, want %v", err, ErrInvalidFlagset)
+	}
+}
+
+func TestWithExplicitEnv(t *testing.T) {
+	t.Setenv("CMD_EXAMPLE1", "from env var")
+	t.Setenv("CMD_EXAMPLE2", "stray env var")
+	t.Setenv("EXAMPLE3", "stray env var")
+
+	var example1, example2, example3 string
+
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringVar(&example1, "example1", "", "Example flag 1")
+	fs.StringVar(&example2, "example2", "", "Example flag 2")
+	fs.StringVar(&example3, "example3", "", "Example flag 3")
+	fs.Parse([]string{})
+
+	v := New(WithEnv(), WithEnvPrefix("cmd"), WithExplicitEnv("example1"))
+	if err := v.Init(fs); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	if example1 != "from env var" {
+		t.Errorf("example1 = %q, want %q", example1, "from env var")
+	}
+
+	if example2 != "" {
+		t.Errorf("example2 = %q, want empty", example2)
+	}
+
+	if example3 != "" {
+		t.Errorf("example3 = %q, want empty", example3)
+	}
+
+	if v.Viper().IsSet("example2") {
+		t.Errorf("IsSet(%q) = true, want false", "example2")
+	}
+}
+
+func TestInitEmptyConfigValue(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{"empty config value", []Option{WithConfig("testdata/prefix-empty.yml")}, ""},
+		{"no config value", []Option{WithConfig("testdata/empty.yml")}, "app-"},
+		{"no config", nil, "app-"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var prefix string
+
+			fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+			fs.StringVar(&prefix, "prefix", "app-", "Prefix")
+			fs.Parse([]string{})
+
+			if err := New(tt.opts...).Init(fs); err != nil {
+				t.Fatalf("Init() error = %v", err)
+			}
+
+			if prefix != tt.want {
+				t.Errorf("prefix = %q, want %q", prefix, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithConfigBesideExecutable(t *testing.T) {
+	dir, err := executableDir()
+	if err != nil {
+		t.Fatalf("executableDir() error = %v", err)
+	}
+
+	// write a config file next to the test binary
+	config := filepath.Join(dir, "beside.yml")
+	if err := os.WriteFile(config, []byte("example: from config file\n"), 0o600); err != nil {
+		t.Fatalf("WriteFile() error = %v", err)
+	}
+	t.Cleanup(func() { os.Remove(config) })
+
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{"beside executable", []Option{WithConfigBesideExecutable("beside.yml")}, "from config file"},
+		{"missing beside executable", []Option{WithConfigBesideExecutable("missing.yml")}, ""},
+		{"config takes precedence", []Option{WithConfig("testdata/search.yml"), WithConfigBesideExecutable("beside.yml")}, "from config file found in search paths"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New(tt.opts...)
+			if err := v.Init(); err != nil {
+				t.Fatalf("Init() error = %v", err)
+			}
+
+			if got := v.GetString("example"); got != tt.want {
+				t.Errorf("GetString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithEnvBindingErrorsFatal(t *testing.T) {
+	t.Setenv("EXAMPLE", "from env var")
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		wantErr bool
+	}{
+		{"invalid bind", []Option{WithEnvVars("example", "")}, true},
+		{"invalid bind with prefixes", []Option{WithEnvVars("example", ""), WithEnvPrefixes("cmd", "")}, true},
+		{"invalid bind fatal", []Option{WithEnvVars("example", ""), WithEnvBindingErrorsFatal(true)}, true},
+		{"invalid bind lenient", []Option{WithEnvVars("example", ""), WithEnvBindingErrorsFatal(false)}, false},
+		{"valid bind", []Option{WithEnvVars("example")}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New(tt.opts...)
+			err := v.Init()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Init() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				if !errors.Is(err, ErrBindEnv) {
+					t.Errorf("Init() error = %v, want %v", err, ErrBindEnv)
+				}
+
+				return
+			}
+
+			if got := v.GetString("example"); got != "from env var" {
+				t.Errorf("GetString() = %q, want %q", got, "from env var")
+			}
+		})
+	}
+}
+
+func TestWithEnvCaseInsensitive(t *testing.T) {
+	t.Setenv("cmd_example1", "from lowercase env var")
+	t.Setenv("Cmd_Example2", "from mixed case env var")
+	t.Setenv("CMD_EXAMPLE3", "from uppercase env var")
+	t.Setenv("cmd_example3", "from lowercase env var")
+
+	tests := []struct {
+		name string
+		opts []Option
+		want map[string]string
+	}{
+		{
+			"prefix",
+			[]Option{WithEnvPrefix("cmd"), WithEnvCaseInsensitive()},
+			map[string]string{"example1": "from lowercase env var", "example2": "from mixed case env var", "example3": "from uppercase env var"},
+		},
+		{
+			"prefixes",
+			[]Option{WithEnvPrefixes("other", "cmd"), WithEnvCaseInsensitive()},
+			map[string]string{"example1": "from lowercase env var", "example2": "from mixed case env var", "example3": "from uppercase env var"},
+		},
+		{
+			"case sensitive",
+			[]Option{WithEnvPrefix("cmd")},
+			map[string]string{"example1": "", "example2": "", "example3": "from uppercase env var"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+			for key := range tt.want {
+				fs.String(key, "", "Example flag")
+			}
+			fs.Parse([]string{})
+
+			if err := New(tt.opts...).Init(fs); err != nil {
+				t.Fatalf("Init() error = %v", err)
+			}
+
+			for key, want := range tt.want {
+				if got, _ := fs.GetString(key); got != want {
+					t.Errorf("flag %s = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestConflictingOptions(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want string
+	}{
+		{"config and optional config", []Option{WithConfig("example.yml"), WithOptionalConfig("example.yml")}, "WithConfig and WithOptionalConfig"},
+		{"optional config name and config", []Option{WithOptionalConfigName("example"), WithConfig("example.yml")}, "WithConfig and WithOptionalConfigName"},
+		{"env prefix and prefixes", []Option{WithEnvPrefixes("cmd"), WithEnvPrefix("cmd")}, "WithEnvPrefix and WithEnvPrefixes"},
+		{"env key replacers", []Option{WithEnvKeyReplacer(strings.NewReplacer("-", "_")), WithEnvKeyReplacerDefault()}, "WithEnvKeyReplacer and WithEnvKeyReplacerDefault"},
+		{"config reader and fs", []Option{WithConfigReader(strings.NewReader(""), "yaml"), WithConfigFS(fstest.MapFS{}, "config.yml")}, "WithConfigReader and WithConfigFS"},
+		{"config reader and encoded env", []Option{WithConfigReader(strings.NewReader(""), "yaml"), WithConfigFromEncodedEnv("CONFIG", "yaml", EncodingBase64)}, "WithConfigReader and WithConfigFromEncodedEnv"},
+		{"config and config name", []Option{WithConfig("example.yml"), WithConfigName("example")}, ""},
+		{"config twice", []Option{WithConfig("missing.yml"), WithConfig("example.yml")}, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := New(tt.opts...).Init()
+			if tt.want == "" {
+				if err != nil {
+					t.Errorf("Init() error = %v, want nil", err)
+				}
+
+				return
+			}
+
+			if !errors.Is(err, ErrConflictingOptions) {
+				t.Fatalf("Init() error = %v, want %v", err, ErrConflictingOptions)
+			}
+
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("Init() error = %v, want options %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithMaxConfigSize(t *testing.T) {
+	info, err := os.Stat("testdata/base.yml")
+	if err != nil {
+		t.Fatalf("Stat() error = %v", err)
+	}
+
+	size := info.Size()
+	fsys := fstest.MapFS{"base.yml": {Data: make([]byte, size)}}
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		wantErr bool
+	}{
+		{"unlimited", []Option{WithConfig("testdata/base.yml")}, false},
+		{"within limit", []Option{WithConfig("testdata/base.yml"), WithMaxConfigSize(size)}, false},
+		{"over limit", []Option{WithConfig("testdata/base.yml"), WithMaxConfigSize(size - 1)}, true},
+		{"merge over limit", []Option{WithMergeConfig("testdata/search.yml", "testdata/base.yml"), WithMaxConfigSize(size - 1)}, true},
+		{"fs over limit", []Option{WithConfigFS(fsys, "base.yml"), WithMaxConfigSize(size - 1)}, true},
+		{"missing optional config", []Option{WithOptionalConfig("testdata/missing.yml"), WithMaxConfigSize(1)}, false},
+		{"search within limit", []Option{WithConfigName("base"), WithConfigPaths("testdata"), WithMaxConfigSize(size)}, false},
+		{"search over limit", []Option{WithConfigName("base"), WithConfigPaths("testdata"), WithMaxConfigSize(size - 1)}, true},
+		{"search any format over limit", []Option{WithConfigNameAnyFormat("base", "testdata"), WithMaxConfigSize(size - 1)}, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := New(tt.opts...).Init()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Init() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr && !errors.Is(err, ErrConfigTooLarge) {
+				t.Errorf("Init() error = %v, want %v", err, ErrConfigTooLarge)
+			}
+		})
+	}
+}
+
+func TestWithFlagEnv(t *testing.T) {
+	tests := []struct {
+		name string
+		env  map[string]string
+		opts []Option
+		want string
+	}{
+		{"flag env", map[string]string{"DATABASE_URL": "from flag env"}, []Option{WithFlagEnv("db-url", "DATABASE_URL")}, "from flag env"},
+		{"second flag env", map[string]string{"DB": "from second flag env"}, []Option{WithFlagEnv("db-url", "DATABASE_URL", "DB")}, "from second flag env"},
+		{"not automatic", map[string]string{"DB_URL": "from env"}, []Option{WithFlagEnv("db-url", "DATABASE_URL")}, ""},
+		{"automatic takes precedence", map[string]string{"CMD_DB_URL": "from env", "DATABASE_URL": "from flag env"}, []Option{WithEnvPrefix("cmd"), WithEnvKeyReplacerDefault(), WithFlagEnv("db-url", "DATABASE_URL")}, "from env"},
+		{"with automatic", map[string]string{"DATABASE_URL": "from flag env"}, []Option{WithEnvPrefix("cmd"), WithEnvKeyReplacerDefault(), WithFlagEnv("db-url", "DATABASE_URL")}, "from flag env"},
+		{"with env vars", map[string]string{"DATABASE_URL": "from flag env"}, []Option{WithEnvVars("other"), WithFlagEnv("db-url", "DATABASE_URL")}, "from flag env"},
+		{"with prefixes", map[string]string{"DATABASE_URL": "from flag env"}, []Option{WithEnvPrefixes("cmd"), WithFlagEnv("db-url", "DATABASE_URL")}, "from flag env"},
+		{"with flag key prefix", map[string]string{"DATABASE_URL": "from flag env"}, []Option{WithFlagKeyPrefix("app"), WithFlagEnv("db-url", "DATABASE_URL")}, "from flag env"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for key, value := range tt.env {
+				t.Setenv(key, value)
+			}
+
+			var dbURL string
+
+			fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+			fs.StringVar(&dbURL, "db-url", "", "Database URL")
+			fs.Parse([]string{})
+
+			if err := New(tt.opts...).Init(fs); err != nil {
+				t.Fatalf("Init() error = %v", err)
+			}
+
+			if dbURL != tt.want {
+				t.Errorf("db-url = %q, want %q", dbURL, tt.want)
+			}
+		})
+	}
+}
+
+func TestSnapshotRestore(t *testing.T) {
+	v := New(WithConfig("testdata/prefix.yml"))
+	if err := v.Init(); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	snapshot := v.Snapshot()
+
+	// modifying the snapshot does not modify the settings
+	snapshot["db"].(map[string]any)["timeout"] = "1h"
+	if got := v.GetString("db.timeout"); got != "10s" {
+		t.Errorf("GetString() = %q after modifying snapshot, want %q", got, "10s")
+	}
+
+	v.Restore(snapshot)
+	if got := v.GetString("db.timeout"); got != "1h" {
+		t.Errorf("GetString() = %q after Restore, want %q", got, "1h")
+	}
+
+	// modifying the snapshot after restoring does not modify the settings
+	snapshot["db"].(map[string]any)["timeout"] = "2h"
+	if got := v.GetString("db.timeout"); got != "1h" {
+		t.Errorf("GetString() = %q after modifying restored snapshot, want %q", got, "1h")
+	}
+}
+
+func TestWithConfigNameAnyFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		want    string
+		wantErr bool
+	}{
+		{"yaml", []Option{WithConfigNameAnyFormat("app", "testdata/formats/yaml")}, "from yaml config file", false},
+		{"json", []Option{WithConfigNameAnyFormat("app", "testdata/formats/json")}, "from json config file", false},
+		{"first path wins", []Option{WithConfigNameAnyFormat("app", "testdata/formats/json", "testdata/formats/yaml")}, "from json config file", false},
+		{"first extension wins", []Option{WithConfigNameAnyFormat("app", "testdata/formats/both")}, "from toml config file", false},
+		{"config type ignored before", []Option{WithConfigType("yaml"), WithConfigNameAnyFormat("app", "testdata/formats/json")}, "from json config file", false},
+		{"config type ignored after", []Option{WithConfigNameAnyFormat("app", "testdata/formats/json"), WithConfigType("yaml")}, "from json config file", false},
+		{"with config paths", []Option{WithConfigPaths("testdata/formats/yaml"), WithConfigNameAnyFormat("app")}, "from yaml config file", false},
+		{"missing", []Option{WithConfigNameAnyFormat("missing", "testdata/formats/yaml")}, "", true},
+		{"missing optional", []Option{WithOptionalConfigNameAnyFormat("missing", "testdata/formats/yaml")}, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New(tt.opts...)
+			err := v.Init()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Init() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if got := v.GetString("example"); got != tt.want {
+				t.Errorf("GetString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestOptionOrder(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want map[string]string
+	}{
+		{
+			"config after merge config",
+			[]Option{WithMergeConfig("testdata/base.yml", "testdata/override.yml"), WithConfig("testdata/base.yml")},
+			map[string]string{"example1": "from base config file", "example2": "overridden by override config file"},
+		},
+		{
+			"merge config after config",
+			[]Option{WithConfig("testdata/search.yml"), WithMergeConfig("testdata/base.yml", "testdata/override.yml")},
+			map[string]string{"example": ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New(tt.opts...)
+			if err := v.Init(); err != nil {
+				t.Fatalf("Init() error = %v", err)
+			}
+
+			for key, want := range tt.want {
+				if got := v.GetString(key); got != want {
+					t.Errorf("GetString(%q) = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBindEnvToFlag(t *testing.T) {
+	t.Setenv("MYAPP_TOKEN", "from env var")
+	t.Setenv("OTHER", "stray env var")
+
+	var token, other string
+
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringVar(&token, "token", "", "Token")
+	fs.StringVar(&other, "other", "", "Other")
+	fs.Parse([]string{})
+
+	v := New()
+	if err := v.BindEnvToFlag("MYAPP_TOKEN", fs.Lookup("token")); err != nil {
+		t.Fatalf("BindEnvToFlag() error = %v", err)
+	}
+
+	if err := v.BindEnvToFlag("MYAPP_TOKEN", fs.Lookup("missing")); !errors.Is(err, ErrBindEnv) {
+		t.Errorf("BindEnvToFlag() error = %v, want %v", err, ErrBindEnv)
+	}
+
+	if err := v.Init(fs); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	if token != "from env var" {
+		t.Errorf("token = %q, want %q", token, "from env var")
+	}
+
+	if other != "" {
+		t.Errorf("other = %q, want empty", other)
+	}
+}
+
+func TestWithFlagApplyHookError(t *testing.T) {
+	errHook := errors.New("hook error")
+
+	var example1, example2 string
+
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringVar(&example1, "example1", "", "Example flag 1")
+	fs.StringVar(&example2, "example2", "", "Example flag 2")
+	fs.Parse([]string{})
+
+	var calls int
+	hook := func(f *pflag.Flag, value string, source string) error {
+		calls++
+
+		return errHook
+	}
+
+	err := New(WithConfig("testdata/base.yml"), WithFlagApplyHook(hook)).Init(fs)
+	if !errors.Is(err, errHook) {
+		t.Errorf("Init() error = %v, want %v", err, errHook)
+	}
+
+	if calls != 1 {
+		t.Errorf("hook called %d times, want 1", calls)
+	}
+
+	// the first flag is restored and the second is never modified
+	if example1 != "" || fs.Changed("example1") {
+		t.Errorf("example1 = %q, want empty and unchanged", example1)
+	}
+
+	if example2 != "" {
+		t.Errorf("example2 = %q, want empty", example2)
+	}
+}
+
+func TestInitValidationFailure(t *testing.T) {
+	errInvalid := errors.New("invalid config")
+
+	var example1, example2 string
+	var list []string
+
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringVar(&example1, "example1", "default1", "Example flag 1")
+	fs.StringVar(&example2, "example2", "", "Example flag 2")
+	fs.StringSliceVar(&list, "list", []string{"a"}, "List flag")
+	fs.Parse([]string{"--example2", "from flag"})
+
+	t.Setenv("LIST", "b c")
+
+	v := New(
+		WithConfig("testdata/base.yml"),
+		WithEnv(),
+		WithValidate(func(*viper.Viper) error { return errInvalid }),
+	)
+	if err := v.Init(fs); !errors.Is(err, errInvalid) {
+		t.Fatalf("Init() error = %v, want %v", err, errInvalid)
+	}
+
+	if example1 != "default1" || fs.Changed("example1") {
+		t.Errorf("example1 = %q, want %q and unchanged", example1, "default1")
+	}
+
+	if example2 != "from flag" {
+		t.Errorf("example2 = %q, want %q", example2, "from flag")
+	}
+
+	if !slices.Equal(list, []string{"a"}) || fs.Changed("list") {
+		t.Errorf("list = %v, want %v and unchanged", list, []string{"a"})
+	}
+
+	// missing required keys also leave the flags untouched
+	v = New(WithConfig("testdata/base.yml"), WithRequired("missing"))
+	if err := v.Init(fs); !errors.Is(err, ErrMissingRequired) {
+		t.Fatalf("Init() error = %v, want %v", err, ErrMissingRequired)
+	}
+
+	if example1 != "default1" || fs.Changed("example1") {
+		t.Errorf("example1 = %q, want %q and unchanged", example1, "default1")
+	}
+}
+
+func TestInitDurationFlag(t *testing.T) {
+	tests := []struct {
+		name   string
+		config string
+		want   time.Duration
+	}{
+		{"duration string", "timeout: 30s\n", 30 * time.Second},
+		{"nanoseconds", "timeout: 30000000000\n", 30 * time.Second},
+		{"nanoseconds string", "timeout: \"30000000000\"\n", 30 * time.Second},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var timeout time.Duration
+
+			fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+			fs.DurationVar(&timeout, "timeout", time.Minute, "Timeout")
+			fs.Parse([]string{})
+
+			if err := New(WithConfigReader(strings.NewReader(tt.config), "yaml")).Init(fs); err != nil {
+				t.Fatalf("Init() error = %v", err)
+			}
+
+			if timeout != tt.want {
+				t.Errorf("timeout = %v, want %v", timeout, tt.want)
+			}
+		})
+	}
+}
+
+// fakeRemoteConfig is a remote config provider that returns the config for a path from a map
+type fakeRemoteConfig map[string]string
+
+func (f fakeRemoteConfig) Get(rp viper.RemoteProvider) (io.Reader, error) {
+	config, ok := f[rp.Path()]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+
+	return strings.NewReader(config), nil
+}
+
+func (f fakeRemoteConfig) Watch(rp viper.RemoteProvider) (io.Reader, error) {
+	return f.Get(rp)
+}
+
+func (f fakeRemoteConfig) WatchChannel(rp viper.RemoteProvider) (<-chan *viper.RemoteResponse, chan bool) {
+	return nil, nil
+}
+
+func TestWithRemoteProvider(t *testing.T) {
+	tests := []struct {
+		name    string
+		remote  fakeRemoteConfig
+		opts    []Option
+		want    string
+		wantErr bool
+	}{
+		{"not enabled", nil, []Option{WithRemoteProvider("consul", "localhost:8500", "config/app")}, "", true},
+		{"optional not enabled", nil, []Option{WithOptionalRemoteProvider("consul", "localhost:8500", "config/app")}, "", true},
+		{"unsupported provider", fakeRemoteConfig{}, []Option{WithOptionalRemoteProvider("unknown", "localhost:8500", "config/app")}, "", true},
+		{
+			"remote",
+			fakeRemoteConfig{"config/app": "example: from remote config\n"},
+			[]Option{WithRemoteProvider("consul", "localhost:8500", "config/app"), WithConfigType("yaml")},
+			"from remote config",
+			false,
+		},
+		{
+			"secure remote",
+			fakeRemoteConfig{"config/app": "example: from remote config\n"},
+			[]Option{WithRemoteProviderSecure("consul", "localhost:8500", "config/app", "keyring"), WithConfigType("yaml")},
+			"from remote config",
+			false,
+		},
+		{
+			"first provider that succeeds",
+			fakeRemoteConfig{"config/other": "example: from other remote config\n"},
+			[]Option{WithRemoteProvider("consul", "localhost:8500", "config/app"), WithRemoteProvider("consul", "localhost:8500", "config/other"), WithConfigType("yaml")},
+			"from other remote config",
+			false,
+		},
+		{
+			"config file takes precedence",
+			fakeRemoteConfig{"config/app": "example: from remote config\n"},
+			[]Option{WithRemoteProvider("consul", "localhost:8500", "config/app"), WithConfig("testdata/search.yml")},
+			"from config file found in search paths",
+			false,
+		},
+		{"missing", fakeRemoteConfig{}, []Option{WithRemoteProvider("consul", "localhost:8500", "config/app"), WithConfigType("yaml")}, "", true},
+		{"missing optional", fakeRemoteConfig{}, []Option{WithOptionalRemoteProvider("consul", "localhost:8500", "config/app"), WithConfigType("yaml")}, "", false},
+		{
+			"missing optional and required",
+			fakeRemoteConfig{},
+			[]Option{WithOptionalRemoteProvider("consul", "localhost:8500", "config/app"), WithRemoteProvider("consul", "localhost:8500", "config/other"), WithConfigType("yaml")},
+			"",
+			true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.remote != nil {
+				viper.RemoteConfig = tt.remote
+				t.Cleanup(func() { viper.RemoteConfig = nil })
+			}
+
+			v := New(tt.opts...)
+			err := v.Init()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Init() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				if !errors.Is(err, ErrReadConfig) {
+					t.Errorf("Init() error = %v, want %v", err, ErrReadConfig)
+				}
+
+				return
+			}
+
+			if got := v.GetString("example"); got != tt.want {
+				t.Errorf("GetString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMustInitPanics(t *testing.T) {
+	defer func() {
+		r := recover()
+		err, ok := r.(error)
+		if !ok || !errors.Is(err, ErrReadConfig) {
+			t.Errorf("MustInit() panic = %v, want %v", r, ErrReadConfig)
+		}
+	}()
+
+	New(WithConfig("testdata/missing.yml")).MustInit()
+}
+
+func TestWithEnvExpansion(t *testing.T) {
+	t.Setenv("EXAMPLE_HOST", "example.com")
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		want    string
+		wantErr bool
+	}{
+		{"undefined is empty", []Option{WithConfig("testdata/expand.yml"), WithEnvExpansion()}, "https://example.com:/", false},
+		{"undefined is error", []Option{WithConfig("testdata/expand.yml"), WithStrictEnvExpansion()}, "", true},
+		{"not expanded", []Option{WithConfig("testdata/expand.yml")}, "https://${EXAMPLE_HOST}:$EXAMPLE_PORT/", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New(tt.opts...)
+			err := v.Init()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Init() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				if !errors.Is(err, ErrUndefinedEnvVar) || !strings.Contains(err.Error(), "EXAMPLE_PORT") {
+					t.Errorf("Init() error = %v, want %v naming EXAMPLE_PORT", err, ErrUndefinedEnvVar)
+				}
+
+				return
+			}
+
+			if got := v.GetString("url"); got != tt.want {
+				t.Errorf("GetString() = %q, want %q", got, tt.want)
+			}
+
+			if got := v.GetString("literal"); got != "no references" {
+				t.Errorf("GetString() = %q, want %q", got, "no references")
+			}
+		})
+	}
+}
+
+func TestInitNoOptDefValFlags(t *testing.T) {
+	tests := []struct {
+		name        string
+		config      string
+		args        []string
+		wantVerbose int
+		wantToggle  bool
+	}{
+		{"values", "verbose: 3\ntoggle: true\n", nil, 3, true},
+		{"zero values", "verbose: 0\ntoggle: false\n", nil, 0, false},
+		{"string values", "verbose: \"2\"\ntoggle: \"true\"\n", nil, 2, true},
+		{"count as toggle", "verbose: true\n", nil, 1, false},
+		{"number as bool", "toggle: 2\n", nil, 0, true},
+		{"command line takes precedence", "verbose: 5\ntoggle: false\n", []string{"-vv", "--toggle"}, 2, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var verbose int
+			var toggle bool
+
+			fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+			fs.CountVarP(&verbose, "verbose", "v", "Verbosity")
+			fs.BoolVar(&toggle, "toggle", false, "Toggle")
+			fs.Lookup("toggle").NoOptDefVal = "true"
+			fs.Parse(tt.args)
+
+			if err := New(WithConfigReader(strings.NewReader(tt.config), "yaml")).Init(fs); err != nil {
+				t.Fatalf("Init() error = %v", err)
+			}
+
+			if verbose != tt.wantVerbose {
+				t.Errorf("verbose = %d, want %d", verbose, tt.wantVerbose)
+			}
+
+			if toggle != tt.wantToggle {
+				t.Errorf("toggle = %v, want %v", toggle, tt.wantToggle)
+			}
+		})
+	}
+}
+
+func TestWithIgnoredFlags(t *testing.T) {
+	t.Setenv("VERSION", "true")
+	t.Setenv("EXAMPLE", "from env var")
+
+	var version bool
+	var example string
+
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.BoolVar(&version, "version", false, "Show version")
+	fs.StringVar(&example, "example", "", "Example flag")
+	fs.Parse([]string{})
+
+	v := New(WithEnv(), WithIgnoredFlags("version"))
+	if err := v.Init(fs); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	if version {
+		t.Errorf("version = %v, want false", version)
+	}
+
+	if fs.Changed("version") {
+		t.Errorf("Changed(%q) = true, want false", "version")
+	}
+
+	if example != "from env var" {
+		t.Errorf("example = %q, want %q", example, "from env var")
+	}
+
+	if slices.Contains(v.AllKeys(), "version") {
+		t.Errorf("AllKeys() = %v, should not contain %q", v.AllKeys(), "version")
+	}
+}
+
+func TestWithProfile(t *testing.T) {
+	tests := []struct {
+		name    string
+		env     string
+		args    []string
+		want    string
+		wantErr error
+	}{
+		{"no profile", "", []string{}, ":8080", nil},
+		{"profile from flag", "", []string{"--profile", "prod"}, ":80", nil},
+		{"profile from env var", "dev", []string{}, "localhost:8080", nil},
+		{"flag wins over env var", "dev", []string{"--profile", "prod"}, ":80", nil},
+		{"profile value overridden by flag", "dev", []string{"--listen", ":9090"}, ":9090", nil},
+		{"unknown profile", "", []string{"--profile", "test"}, "", ErrUnknownProfile},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.env != "" {
+				t.Setenv("PROFILE", tt.env)
+			}
+
+			var listen, profile string
+
+			fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+			fs.StringVar(&listen, "listen", "", "Listen address")
+			fs.StringVar(&profile, "profile", "", "Config profile")
+			fs.Parse(tt.args)
+
+			err := New(WithConfig("testdata/profiles.yml"), WithEnv(), WithProfile("profile")).Init(fs)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Init() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr == nil && listen != tt.want {
+				t.Errorf("listen = %q, want %q", listen, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithProfileEnvBinding(t *testing.T) {
+	t.Setenv("APP_PROFILE", "dev")
+
+	lookup := func(name string) (string, bool) {
+		if name == "APP_PROFILE" {
+			return "dev", true
+		}
+
+		return "", false
+	}
+
+	tests := []struct {
+		name string
+		opts []Option
+		args []string
+		want string
+	}{
+		{"prefix", []Option{WithEnvPrefix("app")}, []string{}, "localhost:8080"},
+		{"prefixes", []Option{WithEnvPrefixes("app")}, []string{}, "localhost:8080"},
+		{"flag env", []Option{WithFlagEnv("profile", "APP_PROFILE")}, []string{}, "localhost:8080"},
+		{"lookup", []Option{WithEnvPrefix("app"), WithEnvLookup(lookup)}, []string{}, "localhost:8080"},
+		{"flag wins over lookup", []Option{WithEnvPrefix("app"), WithEnvLookup(lookup)}, []string{"--profile", "prod"}, ":80"},
+		{"lookup overrides flag", []Option{WithEnvPrefix("app"), WithEnvLookup(lookup), WithEnvOverride()}, []string{"--profile", "prod"}, "localhost:8080"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var listen, profile string
+
+			fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+			fs.StringVar(&listen, "listen", "", "Listen address")
+			fs.StringVar(&profile, "profile", "", "Config profile")
+			fs.Parse(tt.args)
+
+			opts := append([]Option{WithConfig("testdata/profiles.yml"), WithProfile("profile")}, tt.opts...)
+			if err := New(opts...).Init(fs); err != nil {
+				t.Fatalf("Init() error = %v", err)
+			}
+
+			if listen != tt.want {
+				t.Errorf("listen = %q, want %q", listen, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithEnvPrefixSeparator(t *testing.T) {
+	t.Setenv("MYAPP__DB_HOST", "from double underscore env var")
+	t.Setenv("MYAPP_DB_HOST", "from single underscore env var")
+	t.Setenv("OLDAPP__DB_PORT", "5432")
+
+	tests := []struct {
+		name string
+		opts []Option
+		want map[string]string
+	}{
+		{
+			"default separator",
+			[]Option{WithEnvPrefix("myapp"), WithEnvKeyReplacerDefault()},
+			map[string]string{"db.host": "from single underscore env var", "db.port": ""},
+		},
+		{
+			"double underscore",
+			[]Option{WithEnvPrefix("myapp"), WithEnvKeyReplacerDefault(), WithEnvPrefixSeparator("__")},
+			map[string]string{"db.host": "from double underscore env var", "db.port": ""},
+		},
+		{
+			"prefixes",
+			[]Option{WithEnvPrefixes("myapp", "oldapp"), WithEnvKeyReplacerDefault(), WithEnvPrefixSeparator("__")},
+			map[string]string{"db.host": "from double underscore env var", "db.port": "5432"},
+		},
+		{
+			"env vars",
+			[]Option{WithEnvPrefix("myapp"), WithEnvKeyReplacerDefault(), WithEnvPrefixSeparator("__"), WithEnvVars("db.port")},
+			map[string]string{"db.host": "", "db.port": ""},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+			for key := range tt.want {
+				fs.String(key, "", "Example flag")
+			}
+			fs.Parse([]string{})
+
+			if err := New(tt.opts...).Init(fs); err != nil {
+				t.Fatalf("Init() error = %v", err)
+			}
+
+			for key, want := range tt.want {
+				if got, _ := fs.GetString(key); got != want {
+					t.Errorf("flag %s = %q, want %q", key, got, want)
+				}
+			}
+		})
+	}
+
+	// keys bound directly use the separator too
+	v := New(WithEnvPrefix("myapp"), WithEnvKeyReplacerDefault(), WithEnvPrefixSeparator("__"))
+	if err := v.BindEnv("db.host"); err != nil {
+		t.Fatalf("BindEnv() error = %v", err)
+	}
+
+	if got := v.GetString("db.host"); got != "from double underscore env var" {
+		t.Errorf("GetString() = %q, want %q", got, "from double underscore env var")
+	}
+}
+
+func TestReloadChanged(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(config, []byte("example1: from config file\nexample2: from config file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name         string
+		changedOnly  bool
+		wantExample1 string
+		wantExample2 string
+	}{
+		{"all", false, "from updated config file", "from updated config file"},
+		{"changed", true, "changed at runtime", "from updated config file"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := os.WriteFile(config, []byte("example1: from config file\nexample2: from config file\n"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			var example1, example2 string
+
+			fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+			fs.StringVar(&example1, "example1", "", "Example flag 1")
+			fs.StringVar(&example2, "example2", "", "Example flag 2")
+			fs.Parse([]string{})
+
+			v := New(WithConfig(config), WithWatchReloadMode(ReloadChanged))
+			if err := v.Init(fs); err != nil {
+				t.Fatalf("Init() error = %v", err)
+			}
+
+			// the snapshot is normally taken when watching starts
+			snapshot, err := v.configSnapshot()
+			if err != nil {
+				t.Fatalf("configSnapshot() error = %v", err)
+			}
+			v.lastConfig = snapshot
+
+			// change a flag at runtime and only the other key in the config file
+			fs.Set("example1", "changed at runtime")
+			if err := os.WriteFile(config, []byte("example1: from config file\nexample2: from updated config file\n"), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			// a full reload sees every key in the config
+			if !tt.changedOnly {
+				if err := os.WriteFile(config, []byte("example1: from updated config file\nexample2: from updated config file\n"), 0o600); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			mode := ReloadAll
+			if tt.changedOnly {
+				mode = ReloadChanged
+			}
+
+			if err := v.reload(mode); err != nil {
+				t.Fatalf("reload() error = %v", err)
+			}
+
+			if example1 != tt.wantExample1 {
+				t.Errorf("example1 = %q, want %q", example1, tt.wantExample1)
+			}
+
+			if example2 != tt.wantExample2 {
+				t.Errorf("example2 = %q, want %q", example2, tt.wantExample2)
+			}
+		})
+	}
+}
+
+func TestChangedConfigKeys(t *testing.T) {
+	previous := map[string]any{"same": "value", "changed": "old", "removed": "value", "list": []any{"a", "b"}}
+	current := map[string]any{"same": "value", "changed": "new", "added": "value", "list": []any{"a", "b"}}
+
+	got := changedConfigKeys(previous, current)
+	want := map[string]bool{"changed": true, "removed": true, "added": true}
+	if !maps.Equal(got, want) {
+		t.Errorf("changedConfigKeys() = %v, want %v", got, want)
+	}
+}
+
+func TestInitSliceFlagFromEnv(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		want  []string
+	}{
+		{"comma separated", "a,b,c", []string{"a", "b", "c"}},
+		{"single value", "a", []string{"a"}},
+		{"quoted value with comma", `a,"b,c"`, []string{"a", "b,c"}},
+		{"value with spaces", "a b,c", []string{"a b", "c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("MYAPP_HOSTS", tt.value)
+
+			var hosts []string
+			var ports []int
+
+			fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+			fs.StringSliceVar(&hosts, "hosts", []string{"localhost"}, "Hosts")
+			fs.IntSliceVar(&ports, "ports", nil, "Ports")
+			fs.Parse([]string{})
+
+			t.Setenv("MYAPP_PORTS", "80,443")
+
+			if err := New(WithEnvPrefix("myapp")).Init(fs); err != nil {
+				t.Fatalf("Init() error = %v", err)
+			}
+
+			if !slices.Equal(hosts, tt.want) {
+				t.Errorf("hosts = %q, want %q", hosts, tt.want)
+			}
+
+			if !slices.Equal(ports, []int{80, 443}) {
+				t.Errorf("ports = %v, want %v", ports, []int{80, 443})
+			}
+		})
+	}
+}
+
+func TestWithLazyConfig(t *testing.T) {
+	t.Run("valid config", func(t *testing.T) {
+		var example1 string
+
+		fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+		fs.StringVar(&example1, "example1", "", "Example flag 1")
+		fs.Parse([]string{})
+
+		v := New(WithConfig("testdata/base.yml"), WithLazyConfig())
+		if err := v.Init(fs); err != nil {
+			t.Fatalf("Init() error = %v", err)
+		}
+
+		if v.configRead {
+			t.Error("config read during Init")
+		}
+
+		// values from the config are not applied to flags
+		if example1 != "" {
+			t.Errorf("example1 = %q, want empty", example1)
+		}
+
+		if got := v.GetString("example1"); got != "from base config file" {
+			t.Errorf("GetString() = %q, want %q", got, "from base config file")
+		}
+
+		if err := v.ConfigError(); err != nil {
+			t.Errorf("ConfigError() error = %v", err)
+		}
+
+		if got := v.ConfigFileUsed(); got != "testdata/base.yml" {
+			t.Errorf("ConfigFileUsed() = %q, want %q", got, "testdata/base.yml")
+		}
+	})
+
+	t.Run("invalid config", func(t *testing.T) {
+		v := New(WithConfig("testdata/invalid.yml"), WithLazyConfig())
+		if err := v.Init(); err != nil {
+			t.Fatalf("Init() error = %v", err)
+		}
+
+		var config struct{ Example string }
+		if err := v.Unmarshal(&config); !errors.Is(err, ErrConfigParse) {
+			t.Errorf("Unmarshal() error = %v, want %v", err, ErrConfigParse)
+		}
+
+		// the error is returned on every access
+		if _, err := Get[string](v, "example"); !errors.Is(err, ErrConfigParse) {
+			t.Errorf("Get() error = %v, want %v", err, ErrConfigParse)
+		}
+
+		if err := v.ConfigError(); !errors.Is(err, ErrConfigParse) {
+			t.Errorf("ConfigError() error = %v, want %v", err, ErrConfigParse)
+		}
+	})
+
+	t.Run("env as config", func(t *testing.T) {
+		t.Setenv("MYAPP_EXAMPLE1", "from env var")
+
+		v := New(WithConfig("testdata/base.yml"), WithEnvAsConfig("myapp"), WithLazyConfig())
+		if err := v.Init(); err != nil {
+			t.Fatalf("Init() error = %v", err)
+		}
+
+		if got := v.GetString("example1"); got != "from env var" {
+			t.Errorf("GetString() = %q, want %q", got, "from env var")
+		}
+
+		if got := v.GetString("example2"); got != "overridden by override config file" {
+			t.Errorf("GetString() = %q, want %q", got, "overridden by override config file")
+		}
+	})
+}
+
+func TestWithStringNormalizer(t *testing.T) {
+	t.Setenv("PASSWORD", "secret\n")
+	t.Setenv("PORT", " 8080 ")
+	t.Setenv("HOSTS", "a , b")
+
+	tests := []struct {
+		name         string
+		opts         []Option
+		wantPassword string
+		wantPort     int
+		wantHosts    []string
+	}{
+		{"without normalizer", []Option{WithEnv()}, "secret\n", 0, []string{"a ", " b"}},
+		{"trim space", []Option{WithEnv(), WithStringNormalizer(strings.TrimSpace)}, "secret", 8080, []string{"a", "b"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var password string
+			var port int
+			var hosts []string
+
+			fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+			fs.StringVar(&password, "password", "", "Password")
+			fs.IntVar(&port, "port", 0, "Port")
+			fs.StringSliceVar(&hosts, "hosts", nil, "Hosts")
+			fs.Parse([]string{})
+
+			if err := New(tt.opts...).Init(fs); err != nil {
+				t.Fatalf("Init() error = %v", err)
+			}
+
+			if password != tt.wantPassword {
+				t.Errorf("password = %q, want %q", password, tt.wantPassword)
+			}
+
+			if port != tt.wantPort {
+				t.Errorf("port = %d, want %d", port, tt.wantPort)
+			}
+
+			if !slices.Equal(hosts, tt.wantHosts) {
+				t.Errorf("hosts = %q, want %q", hosts, tt.wantHosts)
+			}
+		})
+	}
+}
+
+// levelValue is a custom pflag.Value that only accepts a fixed set of values
+type levelValue string
+
+func (l *levelValue) String() string { return string(*l) }
+
+func (l *levelValue) Set(s string) error {
+	switch s {
+	case "debug", "info", "warn", "error":
+		*l = levelValue(s)
+
+		return nil
+	}
+
+	return fmt.Errorf("invalid level %q", s)
+}
+
+func (l *levelValue) Type() string { return "level" }
+
+// tagsValue is a custom pflag.Value that also implements pflag.SliceValue
+type tagsValue []string
+
+func (t *tagsValue) String() string { return "[" + strings.Join(*t, ",") + "]" }
+
+func (t *tagsValue) Set(s string) error {
+	*t = append(*t, strings.Split(s, ",")...)
+
+	return nil
+}
+
+func (t *tagsValue) Type() string { return "tags" }
+
+func (t *tagsValue) Append(s string) error { return t.Set(s) }
+
+func (t *tagsValue) Replace(s []string) error {
+	*t = slices.Clone(s)
+
+	return nil
+}
+
+func (t *tagsValue) GetSlice() []string { return slices.Clone(*t) }
+
+func TestInitCustomValue(t *testing.T) {
+	tests := []struct {
+		name      string
+		level     string
+		tags      string
+		args      []string
+		wantLevel string
+		wantTags  []string
+	}{
+		{"defaults", "", "", []string{}, "info", []string{"default"}},
+		{"from env vars", "debug", "a,b", []string{}, "debug", []string{"a", "b"}},
+		{"invalid value is ignored", "verbose", "", []string{}, "info", []string{"default"}},
+		{"command line wins", "debug", "a,b", []string{"--level", "warn", "--tags", "c"}, "warn", []string{"default", "c"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.level != "" {
+				t.Setenv("LEVEL", tt.level)
+			}
+
+			if tt.tags != "" {
+				t.Setenv("TAGS", tt.tags)
+			}
+
+			level := levelValue("info")
+			tags := tagsValue{"default"}
+
+			fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+			fs.Var(&level, "level", "Log level")
+			fs.Var(&tags, "tags", "Tags")
+			if err := fs.Parse(tt.args); err != nil {
+				t.Fatal(err)
+			}
+
+			if err := New(WithEnv()).Init(fs); err != nil {
+				t.Fatalf("Init() error = %v", err)
+			}
+
+			if string(level) != tt.wantLevel {
+				t.Errorf("level = %q, want %q", level, tt.wantLevel)
+			}
+
+			if !slices.Equal(tags, tt.wantTags) {
+				t.Errorf("tags = %q, want %q", tags, tt.wantTags)
+			}
+		})
+	}
+}
+
+func TestWithConfigURL(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/config.yml":
+			fmt.Fprint(w, "example: from url\n")
+		case "/config":
+			fmt.Fprint(w, `{"example": "from url without extension"}`)
+		case "/invalid.yml":
+			fmt.Fprint(w, "example: [\n")
+		case "/error.yml":
+			http.Error(w, "internal error", http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		want    string
+		wantErr error
+	}{
+		{"config", []Option{WithConfigURL(srv.URL+"/config.yml", "")}, "from url", nil},
+		{"config with type", []Option{WithConfigURL(srv.URL+"/config", "json")}, "from url without extension", nil},
+		{"missing config", []Option{WithConfigURL(srv.URL+"/missing.yml", "")}, "", os.ErrNotExist},
+		{"missing optional config", []Option{WithOptionalConfigURL(srv.URL+"/missing.yml", "")}, "", nil},
+		{"invalid optional config", []Option{WithOptionalConfigURL(srv.URL+"/invalid.yml", "")}, "", ErrConfigParse},
+		{"server error", []Option{WithOptionalConfigURL(srv.URL+"/error.yml", "")}, "", ErrReadConfig},
+		{"too large", []Option{WithConfigURL(srv.URL+"/config.yml", ""), WithMaxConfigSize(5)}, "", ErrConfigTooLarge},
+		{"custom client", []Option{WithConfigURL(srv.URL+"/config.yml", ""), WithHTTPClient(&http.Client{Timeout: time.Second})}, "from url", nil},
+		{"config type", []Option{WithConfigType("json"), WithConfigURL(srv.URL+"/config", "")}, "from url without extension", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New(tt.opts...)
+			err := v.Init()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Init() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr == nil {
+				if got := v.GetString("example"); got != tt.want {
+					t.Errorf("GetString() = %q, want %q", got, tt.want)
+				}
+			}
+		})
+	}
+
+	// a cancelled context stops the request
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := New(WithConfigURL(srv.URL+"/config.yml", "")).InitContext(ctx); !errors.Is(err, context.Canceled) {
+		t.Errorf("InitContext() error = %v, want %v", err, context.Canceled)
+	}
+
+	// the type of the URL does not replace the type set for other config
+	v := New(WithConfigType("toml"), WithConfigURL(srv.URL+"/config", "json"))
+	if err := v.Init(); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	if v.configType != "toml" {
+		t.Errorf("configType = %q, want %q", v.configType, "toml")
+	}
+}
+
+func TestWithNonEmptyConfig(t *testing.T) {
+	dir := t.TempDir()
+	empty := filepath.Join(dir, "empty.yml")
+	if err := os.WriteFile(empty, nil, 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	comments := filepath.Join(dir, "comments.yml")
+	if err := os.WriteFile(comments, []byte("---\n# nothing here\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	fsys := fstest.MapFS{"empty.yml": {Data: []byte{}}}
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		wantErr bool
+	}{
+		{"empty config allowed", []Option{WithConfig(empty)}, false},
+		{"empty config", []Option{WithConfig(empty), WithNonEmptyConfig()}, true},
+		{"comments only", []Option{WithConfig(comments), WithNonEmptyConfig()}, true},
+		{"empty optional config", []Option{WithOptionalConfig(empty), WithNonEmptyConfig()}, true},
+		{"missing optional config", []Option{WithOptionalConfig(filepath.Join(dir, "missing.yml")), WithNonEmptyConfig()}, false},
+		{"empty config with defaults", []Option{WithConfig(empty), WithDefaults(map[string]any{"example": "default"}), WithNonEmptyConfig()}, true},
+		{"empty fs config", []Option{WithConfigFS(fsys, "empty.yml"), WithNonEmptyConfig()}, true},
+		{"empty reader", []Option{WithConfigReader(strings.NewReader(""), "yaml"), WithNonEmptyConfig()}, true},
+		{"non-empty config", []Option{WithConfig("testdata/base.yml"), WithNonEmptyConfig()}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := New(tt.opts...).Init()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Init() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr && (!errors.Is(err, ErrEmptyConfig) || !errors.Is(err, ErrReadConfig)) {
+				t.Errorf("Init() error = %v, want %v and %v", err, ErrEmptyConfig, ErrReadConfig)
+			}
+		})
+	}
+}
+
+func TestWithConfigReaderType(t *testing.T) {
+	tests := []struct {
+		name       string
+		config     string
+		readerType string
+		opts       []Option
+	}{
+		{"reader type", `example = "from config reader"`, "toml", nil},
+		{"config type", `{"example": "from config reader"}`, "", []Option{WithConfigType("json")}},
+		{"reader type wins", `example = "from config reader"`, "toml", []Option{WithConfigType("json")}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			// the order of the options must not matter
+			reader := func() Option { return WithConfigReader(strings.NewReader(tt.config), tt.readerType) }
+			for _, opts := range [][]Option{append(tt.opts, reader()), append([]Option{reader()}, tt.opts...)} {
+				v := New(opts...)
+				if err := v.Init(); err != nil {
+					t.Fatalf("Init() error = %v", err)
+				}
+
+				if got := v.GetString("example"); got != "from config reader" {
+					t.Errorf("GetString() = %q, want %q", got, "from config reader")
+				}
+			}
+		})
+	}
+}
+
+func TestInitWithReport(t *testing.T) {
+	t.Setenv("EXAMPLE3", "from env var")
+	t.Setenv("EXTRA", "from env var without a flag")
+
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.String("example1", "", "Example flag 1")
+	fs.String("example2", "", "Example flag 2")
+	fs.String("example3", "", "Example flag 3")
+	fs.String("example5", "flag default", "Example flag 5")
+	fs.Parse([]string{"--example2", "from command line"})
+
+	v := New(
+		WithConfig("testdata/base.yml"),
+		WithEnvVars("example3", "extra"),
+		WithDefaults(map[string]any{"other": "from default"}),
+	)
+	report, err := v.InitWithReport(fs)
+	if err != nil {
+		t.Fatalf("InitWithReport() error = %v", err)
+	}
+
+	want := Report{
+		"example1": {"from base config file", SourceConfig},
+		"example2": {"from command line", SourceFlag},
+		"example3": {"from env var", SourceEnv},
+		"example5": {"flag default", SourceDefault},
+		"extra":    {"from env var without a flag", SourceEnv},
+		"other":    {"from default", SourceDefault},
+	}
+
+	if !maps.Equal(report, want) {
+		t.Errorf("InitWithReport() = %v, want %v", report, want)
+	}
+
+	// values are applied to flags as per Init
+	if got, _ := fs.GetString("example1"); got != "from base config file" {
+		t.Errorf("example1 = %q, want %q", got, "from base config file")
+	}
+}
+
+func TestWithFallbackConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		want    string
+		wantErr error
+	}{
+		{"primary found", []Option{WithConfig("testdata/base.yml"), WithFallbackConfig("testdata/override.yml")}, "from base config file", nil},
+		{"primary missing", []Option{WithConfig("testdata/missing.yml"), WithFallbackConfig("testdata/base.yml")}, "from base config file", nil},
+		{"primary not found by name", []Option{WithConfigName("missing"), WithConfigPaths("testdata"), WithFallbackConfig("testdata/base.yml")}, "from base config file", nil},
+		{"primary invalid", []Option{WithConfig("testdata/invalid.yml"), WithFallbackConfig("testdata/base.yml")}, "", ErrConfigParse},
+		{"fallback missing", []Option{WithConfig("testdata/missing.yml"), WithFallbackConfig("testdata/missing.yml")}, "", os.ErrNotExist},
+		{"optional fallback missing", []Option{WithOptionalConfig("testdata/missing.yml"), WithFallbackConfig("testdata/other.yml")}, "", nil},
+		{"fallback invalid", []Option{WithOptionalConfig("testdata/missing.yml"), WithFallbackConfig("testdata/invalid.yml")}, "", ErrConfigParse},
+		{"fallback only", []Option{WithFallbackConfig("testdata/base.yml")}, "from base config file", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New(tt.opts...)
+			err := v.Init()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Init() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if got := v.GetString("example1"); tt.wantErr == nil && got != tt.want {
+				t.Errorf("GetString() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestWithOverrides(t *testing.T) {
+	t.Setenv("EXAMPLE3", "from env var")
+
+	var example1, example2, example3 string
+
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.StringVar(&example1, "example1", "", "Example flag 1")
+	fs.StringVar(&example2, "example2", "", "Example flag 2")
+	fs.StringVar(&example3, "example3", "", "Example flag 3")
+	fs.Parse([]string{"--example2", "from command line"})
+
+	v := New(
+		WithConfig("testdata/base.yml"),
+		WithEnv(),
+		WithOverrides(map[string]any{"example1": "from override", "Example2": "from override"}),
+		WithOverrides(map[string]any{"example3": "from override", "other": "from override"}),
+	)
+	report, err := v.InitWithReport(fs)
+	if err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	for name, got := range map[string]string{"example1": example1, "example2": example2, "example3": example3} {
+		if got != "from override" {
+			t.Errorf("%s = %q, want %q", name, got, "from override")
+		}
+
+		if report[name].Source != SourceOverride {
+			t.Errorf("report[%q].Source = %q, want %q", name, report[name].Source, SourceOverride)
+		}
+	}
+
+	if got := v.GetString("other"); got != "from override" {
+		t.Errorf("GetString() = %q, want %q", got, "from override")
+	}
+}
+
+func TestWithEnvFilter(t *testing.T) {
+	t.Setenv("MYAPP_EXAMPLE", "from matching env var")
+	t.Setenv("EXAMPLE", "from unprefixed env var")
+	t.Setenv("OTHER", "from unprefixed env var")
+	t.Setenv("MYAPP_COLOUR", "blue")
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		want    map[string]string
+		wantKey string
+		wantErr error
+	}{
+		{
+			"without prefix",
+			[]Option{WithEnvFilter("^MYAPP_.*")},
+			map[string]string{"example": "", "other": "", "myapp_example": "from matching env var"},
+			"myapp_colour",
+			nil,
+		},
+		{
+			"with prefix",
+			[]Option{WithEnvFilter("^MYAPP_.*"), WithEnvPrefix("myapp")},
+			map[string]string{"example": "from matching env var", "other": "", "myapp_example": ""},
+			"colour",
+			nil,
+		},
+		{
+			"excluded key",
+			[]Option{WithEnvFilter("^MYAPP_(COLOUR|OTHER)$"), WithEnvPrefix("myapp")},
+			map[string]string{"example": "", "other": "", "myapp_example": ""},
+			"colour",
+			nil,
+		},
+		{
+			"invalid pattern",
+			[]Option{WithEnvFilter("^MYAPP_(")},
+			map[string]string{"example": ""},
+			"",
+			ErrInvalidEnvFilter,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+			for key := range tt.want {
+				fs.String(key, "", "Example flag")
+			}
+			fs.Parse([]string{})
+
+			v := New(tt.opts...)
+			if err := v.Init(fs); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Init() error = %v, wantErr %v", err, tt.wantErr)
+			} else if err != nil {
+				return
+			}
+
+			for key, want := range tt.want {
+				if got, _ := fs.GetString(key); got != want {
+					t.Errorf("flag %s = %q, want %q", key, got, want)
+				}
+			}
+
+			// matching env vars that are not a known key are bound too
+			if got := v.GetString(tt.wantKey); got != "blue" {
+				t.Errorf("GetString(%q) = %q, want %q", tt.wantKey, got, "blue")
+			}
+		})
+	}
+}
+
+func TestWithEnvLookup(t *testing.T) {
+	// the environment of the process is never used when a lookup function is provided
+	t.Setenv("EXAMPLE_EXAMPLE2", "from process env var")
+	t.Setenv("EXAMPLE_EXAMPLE3", "from process env var")
+
+	env := map[string]string{
+		"EXAMPLE_EXAMPLE1": "from lookup env var",
+		"EXAMPLE_EXAMPLE2": "from lookup env var",
+		"EXAMPLE_EXAMPLE4": "from lookup env var",
+		"EXAMPLE_CONFIG":   "example.yml",
+	}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.String("example1", "", "Example flag 1")
+	fs.String("example2", "", "Example flag 2")
+	fs.String("example3", "default", "Example flag 3")
+	fs.String("example4", "", "Example flag 4")
+	fs.Parse([]string{"--example4", "from command line"})
+
+	v := New(WithEnvPrefix("example"), WithEnvLookup(lookup), WithConfigFileFromEnv("EXAMPLE_CONFIG"))
+	if err := v.Init(fs); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	want := map[string]string{
+		"example1": "from lookup env var",
+		"example2": "from lookup env var",
+		"example3": "env var will take precedence",
+		"example4": "from command line",
+	}
+	for key, value := range want {
+		if got, _ := fs.GetString(key); got != value {
+			t.Errorf("flag %s = %q, want %q", key, got, value)
+		}
+	}
+
+	// the config file was found using the lookup function
+	if got := v.Viper().ConfigFileUsed(); got != "example.yml" {
+		t.Errorf("ConfigFileUsed() = %q, want %q", got, "example.yml")
+	}
+}
+
+func TestWithEnvLookupDotEnv(t *testing.T) {
+	dotenv := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(dotenv, []byte("LOOKUP_EXAMPLE1=from dotenv file\nLOOKUP_EXAMPLE2=from dotenv file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	env := map[string]string{"LOOKUP_EXAMPLE2": "from lookup env var"}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.String("example1", "", "Example flag 1")
+	fs.String("example2", "", "Example flag 2")
+	fs.Parse([]string{})
+
+	if err := New(WithEnvPrefix("lookup"), WithDotEnv(dotenv), WithEnvLookup(lookup)).Init(fs); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	want := map[string]string{
+		"example1": "from dotenv file",
+		"example2": "from lookup env var",
+	}
+	for key, value := range want {
+		if got, _ := fs.GetString(key); got != value {
+			t.Errorf("flag %s = %q, want %q", key, got, value)
+		}
+	}
+
+	// the environment of the process is not modified
+	if value, ok := os.LookupEnv("LOOKUP_EXAMPLE1"); ok {
+		t.Errorf("LOOKUP_EXAMPLE1 = %q, want unset", value)
+	}
+}
+
+func TestDryRunDotEnv(t *testing.T) {
+	dotenv := filepath.Join(t.TempDir(), ".env")
+	if err := os.WriteFile(dotenv, []byte("DRYRUN_EXAMPLE=from dotenv file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.String("example", "", "Example flag")
+	fs.Parse([]string{})
+
+	values, err := New(WithEnvPrefix("dryrun"), WithDotEnv(dotenv)).DryRun(fs)
+	if err != nil {
+		t.Fatalf("DryRun() error = %v", err)
+	}
+
+	if got := values["example"]; got != "from dotenv file" {
+		t.Errorf("DryRun()[%q] = %q, want %q", "example", got, "from dotenv file")
+	}
+
+	// the environment of the process is not modified
+	if value, ok := os.LookupEnv("DRYRUN_EXAMPLE"); ok {
+		t.Errorf("DRYRUN_EXAMPLE = %q, want unset", value)
+	}
+}
+
+func TestWithConfigFromEncodedEnv(t *testing.T) {
+	gzipped := func(s string) []byte {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		zw.Write([]byte(s))
+		zw.Close()
+
+		return buf.Bytes()
+	}
+
+	t.Setenv("CONFIG_PLAIN", "example: from plain env var\n")
+	t.Setenv("CONFIG_BASE64", base64.StdEncoding.EncodeToString([]byte("example: from base64 env var\n")))
+	t.Setenv("CONFIG_GZIP", base64.StdEncoding.EncodeToString(gzipped(`{"example": "from gzip env var"}`)))
+	t.Setenv("CONFIG_INVALID", "not base64!")
+	t.Setenv("CONFIG_NOT_GZIP", base64.StdEncoding.EncodeToString([]byte("example: not gzip\n")))
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		want    string
+		wantErr error
+	}{
+		{"plain", []Option{WithConfigFromEncodedEnv("CONFIG_PLAIN", "yaml", EncodingNone)}, "from plain env var", nil},
+		{"base64", []Option{WithConfigFromEncodedEnv("CONFIG_BASE64", "yaml", EncodingBase64)}, "from base64 env var", nil},
+		{"gzip", []Option{WithConfigFromEncodedEnv("CONFIG_GZIP", "json", EncodingBase64|EncodingGzip)}, "from gzip env var", nil},
+		{"unset", []Option{WithConfig("example.yml"), WithConfigFromEncodedEnv("CONFIG_MISSING", "yaml", EncodingBase64)}, "", nil},
+		{"unset with config type", []Option{WithConfig("testdata/tomlconfig"), WithConfigType("toml"), WithConfigFromEncodedEnv("CONFIG_MISSING", "yaml", EncodingBase64)}, "from extensionless TOML config file", nil},
+		{"set with config type", []Option{WithConfig("testdata/tomlconfig"), WithConfigType("toml"), WithConfigFromEncodedEnv("CONFIG_BASE64", "yaml", EncodingBase64)}, "from base64 env var", nil},
+		{"precedence", []Option{WithConfig("example.yml"), WithConfigFromEncodedEnv("CONFIG_BASE64", "yaml", EncodingBase64)}, "from base64 env var", nil},
+		{"invalid base64", []Option{WithConfigFromEncodedEnv("CONFIG_INVALID", "yaml", EncodingBase64)}, "", ErrReadConfig},
+		{"invalid gzip", []Option{WithConfigFromEncodedEnv("CONFIG_NOT_GZIP", "yaml", EncodingBase64|EncodingGzip)}, "", ErrReadConfig},
+		{"too large", []Option{WithConfigFromEncodedEnv("CONFIG_GZIP", "json", EncodingBase64|EncodingGzip), WithMaxConfigSize(5)}, "", ErrConfigTooLarge},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New(tt.opts...)
+			if err := v.Init(); !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Init() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr == nil {
+				if got := v.GetString("example"); got != tt.want {
+					t.Errorf("GetString() = %q, want %q", got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestWithUnknownKeyHandler(t *testing.T) {
+	tests := []struct {
+		name string
+		opts []Option
+		want []string
+	}{
+		{"misspelled key", []Option{WithConfig("testdata/typo.yml")}, []string{"exmaple"}},
+		{"ignored flag", []Option{WithConfig("testdata/typo.yml"), WithIgnoredFlags("example")}, []string{"example", "exmaple"}},
+		{"no config", nil, nil},
+		{"profiles", []Option{WithConfig("testdata/profiles.yml"), WithProfile("profile")}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+			fs.String("example", "", "Example flag")
+			fs.String("listen", "", "Listen flag")
+			fs.Bool("debug", false, "Debug flag")
+			fs.Parse([]string{})
+
+			var got []string
+			v := New(append(tt.opts, WithUnknownKeyHandler(func(key string) {
+				got = append(got, key)
+			}))...)
+			if err := v.Init(fs); err != nil {
+				t.Fatalf("Init() error = %v", err)
+			}
+
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("unknown keys = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConcurrentInit(t *testing.T) {
+	v := New(WithEnv(), WithConfig("example.yml"))
+
+	var wg sync.WaitGroup
+	instances := make([]*viper.Viper, 50)
+	for i := range instances {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			first := v.Viper()
+
+			fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+			fs.String("example3", "", "Example flag 3")
+			fs.Parse([]string{})
+
+			if err := v.Init(fs); err != nil {
+				t.Errorf("Init() error = %v", err)
+			}
+
+			// the underlying instance is only created once, so is the same after Init
+			if instances[i] = v.Viper(); instances[i] != first {
+				t.Errorf("Viper() returned a different instance after Init")
+			}
+		}()
+	}
+	wg.Wait()
+
+	for i, instance := range instances {
+		if instance != instances[0] {
+			t.Errorf("Viper() call %d returned a different instance", i)
+		}
+	}
+}
+
+func TestRebind(t *testing.T) {
+	t.Setenv("EXAMPLE_EXAMPLE5", "from env var")
+
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.String("example4", "", "Example flag 4")
+	fs.Parse([]string{"--example4", "from command line"})
+
+	v := New(WithConfig("example.yml"), WithEnvPrefixes("example"))
+	if err := v.Init(fs); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	// flags registered after Init, such as by a plugin
+	fs.String("example3", "", "Example flag 3")
+	fs.String("example5", "", "Example flag 5")
+	fs.String("example7", "default", "Example flag 7")
+
+	if err := v.Rebind(fs); err != nil {
+		t.Fatalf("Rebind() error = %v", err)
+	}
+
+	want := map[string]string{
+		"example3": "env var will take precedence",
+		"example4": "from command line",
+		"example5": "from env var",
+		"example7": "default",
+	}
+	for key, value := range want {
+		if got, _ := fs.GetString(key); got != value {
+			t.Errorf("flag %s = %q, want %q", key, got, value)
+		}
+	}
+
+	if err := v.Rebind(nil); !errors.Is(err, ErrInvalidFlagset) {
+		t.Errorf("Rebind() error = %v, want %v", err, ErrInvalidFlagset)
+	}
+}
+
+func TestWithTimeout(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/slow.yml" {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(5 * time.Second):
+			}
+		}
+
+		fmt.Fprint(w, "example: from url\n")
+	}))
+	defer srv.Close()
+
+	tests := []struct {
+		name    string
+		opts    []Option
+		wantErr error
+	}{
+		{"url", []Option{WithConfigURL(srv.URL+"/config.yml", ""), WithTimeout(time.Second)}, nil},
+		{"slow url", []Option{WithConfigURL(srv.URL+"/slow.yml", ""), WithTimeout(50 * time.Millisecond)}, context.DeadlineExceeded},
+		{"file", []Option{WithConfig("example.yml"), WithTimeout(time.Second)}, nil},
+		{"merge files", []Option{WithMergeConfig("testdata/base.yml", "testdata/override.yml"), WithTimeout(time.Second)}, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := New(tt.opts...).Init()
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("Init() error = %v, want %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr != nil && !errors.Is(err, ErrReadConfig) {
+				t.Errorf("Init() error = %v, want %v", err, ErrReadConfig)
+			}
+		})
+	}
+
+	// a context that is done fails with the error from the context, unless checking the file completed first
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	if err := statConfig(ctx, "example.yml"); err != nil && !errors.Is(err, context.Canceled) {
+		t.Errorf("statConfig() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestWithLazyConfigConcurrent(t *testing.T) {
+	loads := 0
+	v := New(WithConfig("example.yml"), WithLazyConfig(), WithValueDecryptor(func(key string, raw []byte) ([]byte, error) {
+		if key == "example3" {
+			loads++
+		}
+
+		return raw, nil
+	}))
+	if err := v.Init(); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	var wg sync.WaitGroup
+	for range 8 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+
+			if got := v.GetString("example3"); got != "env var will take precedence" {
+				t.Errorf("GetString() = %q, want %q", got, "env var will take precedence")
+			}
+		}()
+	}
+	wg.Wait()
+
+	// the config is only read once
+	if loads != 1 {
+		t.Errorf("config read %d times, want 1", loads)
+	}
+}
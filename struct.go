@@ -0,0 +1,165 @@
+package simpleviper
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Errors returned by OptionsFromStruct and BindStruct
+var (
+	// ErrInvalidSpec is returned when the spec is not a struct or has an invalid tag
+	ErrInvalidSpec = errors.New("invalid spec")
+)
+
+// OptionsFromStruct returns the options derived from the tags of the fields of the provided struct, or pointer to a struct, so the config
+// can be declared once as a struct and used with [New] and [Viperlet.Unmarshal].
+//
+// The key for each field is taken from its `mapstructure` tag, or the lowercased field name if there is no tag, with the keys of nested
+// structs joined using "." and fields tagged with "-" being skipped. The following tags are supported:
+//
+//   - `default:"value"` sets a default for the key using [WithDefaults]
+//   - `required:"true"` marks the key as required using [WithRequired]
+//
+// An error wrapping [ErrInvalidSpec] is returned if spec is not a struct, any tag is invalid or a struct is recursive.
+func OptionsFromStruct(spec any) ([]Option, error) {
+	t, err := structType(spec)
+	if err != nil {
+		return nil, err
+	}
+
+	defaults := make(map[string]any)
+	required := make([]string, 0)
+	if err := walkStruct(t, nil, "", ".", func(key string, field reflect.StructField) error {
+		if value, ok := field.Tag.Lookup("default"); ok {
+			defaults[key] = value
+		}
+
+		if value, ok := field.Tag.Lookup("required"); ok {
+			isRequired, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("%w: field %s has invalid required tag %q", ErrInvalidSpec, field.Name, value)
+			}
+
+			if isRequired {
+				required = append(required, key)
+			}
+		}
+
+		return nil
+	}); err != nil {
+		return nil, err
+	}
+
+	opts := make([]Option, 0)
+	if len(defaults) > 0 {
+		opts = append(opts, WithDefaults(defaults))
+	}
+
+	if len(required) > 0 {
+		opts = append(opts, WithRequired(required...))
+	}
+
+	return opts, nil
+}
+
+// BindStruct binds the env var for the key of every field of the provided struct, or pointer to a struct, using the same rules for keys as
+// [OptionsFromStruct] except that nested keys are joined using the delimiter set by [WithKeyDelimiter], so [Viperlet.Unmarshal] includes
+// values from env vars for keys that are not otherwise known. This is needed as [viper.AutomaticEnv] only applies to keys that are known
+// from flags, defaults or config, because env vars cannot be enumerated.
+//
+// Env var names are derived from the key as per [Viperlet.BindEnv], so the prefix and key replacer are honoured, and binding is done even
+// if env binding is not otherwise enabled. As this binds to the underlying [*viper.Viper] instance, the bindings are discarded by
+// [Viperlet.Reset]. An error wrapping [ErrInvalidSpec] is returned if spec is not a struct, or an error wrapping [ErrBindEnv] if any key
+// cannot be bound.
+func (v *Viperlet) BindStruct(spec any) error {
+	t, err := structType(spec)
+	if err != nil {
+		return err
+	}
+
+	return walkStruct(t, nil, "", v.keyDelim(), func(key string, field reflect.StructField) error {
+		if err := v.BindEnv(key); err != nil {
+			return fmt.Errorf("%w %q: %w", ErrBindEnv, key, err)
+		}
+
+		return nil
+	})
+}
+
+// structType returns the type of the struct, or pointer to a struct, returning an error wrapping [ErrInvalidSpec] for any other type
+func structType(spec any) (reflect.Type, error) {
+	t := reflect.TypeOf(spec)
+	if t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t == nil || t.Kind() != reflect.Struct {
+		return nil, fmt.Errorf("%w: %T is not a struct", ErrInvalidSpec, spec)
+	}
+
+	return t, nil
+}
+
+// walkStruct calls fn for each field of the struct that is not a nested struct, along with the key for the field where the keys of nested
+// structs are joined using delim. The types of the structs being walked are tracked in path, so a recursive struct returns an error rather
+// than being walked forever.
+func walkStruct(t reflect.Type, path []reflect.Type, prefix, delim string, fn func(key string, field reflect.StructField) error) error {
+	path = append(path, t)
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+
+		name, opts, _ := strings.Cut(field.Tag.Get("mapstructure"), ",")
+		if name == "-" {
+			continue
+		}
+
+		if name == "" {
+			name = strings.ToLower(field.Name)
+		}
+
+		key := name
+		if prefix != "" {
+			key = prefix + delim + name
+		}
+
+		ft := field.Type
+		if ft.Kind() == reflect.Pointer {
+			ft = ft.Elem()
+		}
+
+		// nested structs are walked with their key as the prefix, or the current prefix if squashed
+		if ft.Kind() == reflect.Struct && ft != reflect.TypeFor[time.Time]() {
+			if _, ok := field.Tag.Lookup("default"); ok {
+				return fmt.Errorf("%w: field %s is a struct so cannot have a default tag", ErrInvalidSpec, field.Name)
+			}
+
+			if slices.Contains(path, ft) {
+				return fmt.Errorf("%w: field %s is a recursive struct", ErrInvalidSpec, field.Name)
+			}
+
+			if slices.Contains(strings.Split(opts, ","), "squash") {
+				key = prefix
+			}
+
+			if err := walkStruct(ft, path, key, delim, fn); err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		if err := fn(key, field); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
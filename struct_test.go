@@ -0,0 +1,189 @@
+package simpleviper
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestOptionsFromStruct(t *testing.T) {
+	type nested struct {
+		Timeout time.Duration `mapstructure:"timeout" default:"30s"`
+	}
+
+	type valid struct {
+		Name     string `default:"example"`
+		Token    string `mapstructure:"token" required:"true"`
+		Optional string `mapstructure:"optional" required:"false"`
+		Skipped  string `mapstructure:"-" required:"true"`
+		Server   nested `mapstructure:"server"`
+		Squashed nested `mapstructure:",squash"`
+		Started  time.Time
+		private  string
+	}
+
+	type node struct {
+		Name string
+		Next *node
+	}
+
+	tests := []struct {
+		name         string
+		spec         any
+		wantErr      bool
+		wantDefaults map[string]any
+		wantRequired []string
+	}{
+		{"not a struct", "string", true, nil, nil},
+		{"nil", nil, true, nil, nil},
+		{"invalid required tag", struct {
+			Token string `required:"maybe"`
+		}{}, true, nil, nil},
+		{"default on struct", struct {
+			Server nested `default:"value"`
+		}{}, true, nil, nil},
+		{"valid", valid{}, false, map[string]any{"name": "example", "server.timeout": "30s", "timeout": "30s"}, []string{"token"}},
+		{"pointer", &valid{}, false, map[string]any{"name": "example", "server.timeout": "30s", "timeout": "30s"}, []string{"token"}},
+		{"empty", struct{}{}, false, nil, nil},
+		{"recursive", node{}, true, nil, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			opts, err := OptionsFromStruct(tt.spec)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("OptionsFromStruct() error = %v, wantErr %v", err, tt.wantErr)
+			}
+
+			if tt.wantErr {
+				if !errors.Is(err, ErrInvalidSpec) {
+					t.Errorf("OptionsFromStruct() error = %v, want %v", err, ErrInvalidSpec)
+				}
+
+				return
+			}
+
+			v := New(opts...)
+			if len(v.defaults) != len(tt.wantDefaults) {
+				t.Errorf("defaults = %v, want %v", v.defaults, tt.wantDefaults)
+			}
+
+			for key, want := range tt.wantDefaults {
+				if got := v.defaults[key]; got != want {
+					t.Errorf("defaults[%q] = %v, want %v", key, got, want)
+				}
+			}
+
+			if len(v.required) != len(tt.wantRequired) {
+				t.Errorf("required = %v, want %v", v.required, tt.wantRequired)
+			}
+
+			for i, want := range tt.wantRequired {
+				if got := v.required[i]; got != want {
+					t.Errorf("required[%d] = %v, want %v", i, got, want)
+				}
+			}
+		})
+	}
+}
+
+func TestBindStruct(t *testing.T) {
+	t.Setenv("MYAPP_NAME", "from env var")
+	t.Setenv("MYAPP_SERVER_TIMEOUT", "1m")
+	t.Setenv("MYAPP_TIMEOUT", "2m")
+
+	type nested struct {
+		Timeout time.Duration `mapstructure:"timeout"`
+	}
+
+	type config struct {
+		Name     string
+		Server   nested `mapstructure:"server"`
+		Squashed nested `mapstructure:",squash"`
+		Missing  string `mapstructure:"missing"`
+	}
+
+	tests := []struct {
+		name     string
+		bind     bool
+		wantName string
+	}{
+		{"without binding", false, ""},
+		{"with binding", true, "from env var"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := New(WithEnvPrefix("myapp"), WithEnvKeyReplacerDefault())
+			if tt.bind {
+				if err := v.BindStruct(&config{}); err != nil {
+					t.Fatalf("BindStruct() error = %v", err)
+				}
+			}
+
+			if err := v.Init(); err != nil {
+				t.Fatalf("Init() error = %v", err)
+			}
+
+			var got config
+			if err := v.Unmarshal(&got); err != nil {
+				t.Fatalf("Unmarshal() error = %v", err)
+			}
+
+			if got.Name != tt.wantName {
+				t.Errorf("Name = %q, want %q", got.Name, tt.wantName)
+			}
+
+			if tt.bind && (got.Server.Timeout != time.Minute || got.Squashed.Timeout != 2*time.Minute) {
+				t.Errorf("Server.Timeout = %v, Squashed.Timeout = %v, want %v and %v", got.Server.Timeout, got.Squashed.Timeout, time.Minute, 2*time.Minute)
+			}
+
+			if got.Missing != "" {
+				t.Errorf("Missing = %q, want empty", got.Missing)
+			}
+		})
+	}
+
+	if err := New().BindStruct("string"); !errors.Is(err, ErrInvalidSpec) {
+		t.Errorf("BindStruct() error = %v, want %v", err, ErrInvalidSpec)
+	}
+
+	type node struct {
+		Next *node
+	}
+
+	if err := New().BindStruct(&node{}); !errors.Is(err, ErrInvalidSpec) {
+		t.Errorf("BindStruct() error = %v, want %v", err, ErrInvalidSpec)
+	}
+}
+
+func TestBindStructKeyDelimiter(t *testing.T) {
+	t.Setenv("MYAPP_SERVER_TIMEOUT", "1m")
+
+	type nested struct {
+		Timeout time.Duration `mapstructure:"timeout"`
+	}
+
+	type config struct {
+		Server nested `mapstructure:"server"`
+	}
+
+	v := New(WithEnvPrefix("myapp"), WithKeyDelimiter("::"), WithEnvKeyReplacer(strings.NewReplacer("::", "_")))
+	if err := v.BindStruct(&config{}); err != nil {
+		t.Fatalf("BindStruct() error = %v", err)
+	}
+
+	if err := v.Init(); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	var got config
+	if err := v.Unmarshal(&got); err != nil {
+		t.Fatalf("Unmarshal() error = %v", err)
+	}
+
+	if got.Server.Timeout != time.Minute {
+		t.Errorf("Server.Timeout = %v, want %v", got.Server.Timeout, time.Minute)
+	}
+}
@@ -0,0 +1,114 @@
+package simpleviper
+
+import (
+	"path/filepath"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// Close stops watching the config file when [WithWatch] is used, waiting for any change that is being handled to complete, so a Viperlet
+// that is no longer needed does not leak the watcher or its goroutine. It is safe to call Close when nothing was started and to call it more
+// than once. Values that have already been read are not affected, however Init must be called again to resume watching.
+//
+// As Close waits for the change being handled, it must not be called from the onChange callback provided using [WithWatch].
+func (v *Viperlet) Close() error {
+	done, err := v.stopWatching()
+	if done != nil {
+		<-done
+	}
+
+	return err
+}
+
+// stopWatching stops the watcher, if any, without waiting for its goroutine to exit, returning a channel that is closed once it has
+func (v *Viperlet) stopWatching() (<-chan struct{}, error) {
+	v.watchMu.Lock()
+	defer v.watchMu.Unlock()
+
+	if v.watcher == nil {
+		return nil, nil
+	}
+
+	err := v.watcher.Close()
+	done := v.watchDone
+
+	v.watcher = nil
+	v.watchDone = nil
+
+	return done, err
+}
+
+// watching returns true if the provided watcher is the one currently in use
+func (v *Viperlet) watching(watcher *fsnotify.Watcher) bool {
+	v.watchMu.Lock()
+	defer v.watchMu.Unlock()
+
+	return v.watcher == watcher
+}
+
+// watch starts watching the directory containing the config file in use, rather than the file itself, so that renames and atomic saves are
+// seen, replacing any existing watcher. [Viperlet.onWatchedConfigChange] is run whenever the file is written, created or the target of a
+// symlink to it changes, such as when a Kubernetes ConfigMap is updated.
+func (v *Viperlet) watch() error {
+	// the existing watcher is not waited for, as the caller holds the lock needed to handle any change in progress, which is then ignored
+	if _, err := v.stopWatching(); err != nil {
+		return err
+	}
+
+	filename := v.Viper().ConfigFileUsed()
+	if filename == "" {
+		return nil
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+
+	configFile := filepath.Clean(filename)
+	realConfigFile, _ := filepath.EvalSymlinks(filename)
+
+	if err := watcher.Add(filepath.Dir(configFile)); err != nil {
+		watcher.Close()
+		return err
+	}
+
+	// the watcher is set before any change can be handled, so the change is not ignored
+	done := make(chan struct{})
+	v.watchMu.Lock()
+	v.watcher = watcher
+	v.watchDone = done
+	v.watchMu.Unlock()
+
+	go func() {
+		defer close(done)
+
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+
+				currentConfigFile, _ := filepath.EvalSymlinks(filename)
+				written := filepath.Clean(event.Name) == configFile && (event.Has(fsnotify.Write) || event.Has(fsnotify.Create))
+				if !written && (currentConfigFile == "" || currentConfigFile == realConfigFile) {
+					continue
+				}
+
+				realConfigFile = currentConfigFile
+				v.onWatchedConfigChange(watcher, event)
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+
+				if v.logger != nil {
+					v.logger.Warn("watching config", "error", err)
+				}
+			}
+		}
+	}()
+
+	return nil
+}
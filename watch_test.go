@@ -0,0 +1,189 @@
+package simpleviper
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/spf13/pflag"
+)
+
+func TestClose(t *testing.T) {
+	// closing when nothing was started is a no-op
+	if err := New().Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	config := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(config, []byte("example: from config file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	// the value is read by the callback, which runs after each reload on the same goroutine, as a change may be seen part way through a write
+	var v *Viperlet
+	changes := make(chan string, 10)
+	v = New(WithConfig(config), WithWatch(func(e fsnotify.Event) {
+		changes <- v.GetString("example")
+	}))
+	if err := v.Init(); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	if err := os.WriteFile(config, []byte("example: from updated config file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	waitForChange(t, changes, "from updated config file")
+
+	if err := v.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// closing again is a no-op
+	if err := v.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// changes are no longer seen once closed
+	for len(changes) > 0 {
+		<-changes
+	}
+
+	if err := os.WriteFile(config, []byte("example: from config file after close\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	select {
+	case got := <-changes:
+		t.Errorf("unexpected config change after Close: %q", got)
+	case <-time.After(200 * time.Millisecond):
+	}
+
+	if got := v.GetString("example"); got != "from updated config file" {
+		t.Errorf("GetString() = %q, want %q", got, "from updated config file")
+	}
+}
+
+func TestWatchReadsFullConfig(t *testing.T) {
+	dir := t.TempDir()
+	base := filepath.Join(dir, "base.yml")
+	override := filepath.Join(dir, "override.yml")
+	if err := os.WriteFile(base, []byte("password: ENC[x]\nexample: from base config file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(override, []byte("extra: from override config file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	decrypt := func(key string, raw []byte) ([]byte, error) {
+		if strings.HasPrefix(string(raw), "ENC[") {
+			return []byte("decrypted"), nil
+		}
+
+		return raw, nil
+	}
+
+	// the values are read by the callback, which runs after each reload on the same goroutine, as a change may be seen part way through a write
+	var v *Viperlet
+	changes := make(chan [3]string, 10)
+	v = New(WithMergeConfig(base, override), WithValueDecryptor(decrypt), WithWatch(func(e fsnotify.Event) {
+		changes <- [3]string{v.GetString("password"), v.GetString("example"), v.GetString("extra")}
+	}))
+	if err := v.Init(); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+	defer v.Close()
+
+	if err := os.WriteFile(base, []byte("password: ENC[x]\nexample: from updated base config file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	waitForChange(t, changes, [3]string{"decrypted", "from updated base config file", "from override config file"})
+}
+
+func TestWatchConcurrentReload(t *testing.T) {
+	config := filepath.Join(t.TempDir(), "config.yml")
+	if err := os.WriteFile(config, []byte("example: from config file\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	fs := pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.String("example", "", "Example flag")
+	fs.Parse([]string{})
+
+	changes := make(chan fsnotify.Event, 100)
+	v := New(WithConfig(config), WithWatchReloadMode(ReloadAll), WithWatch(func(e fsnotify.Event) {
+		changes <- e
+	}))
+	if err := v.Init(fs); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+
+		for i := range 20 {
+			if err := os.WriteFile(config, []byte(fmt.Sprintf("example: update %d\n", i)), 0o600); err != nil {
+				t.Error(err)
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+	}()
+
+	for range 50 {
+		if err := v.Reload(); err != nil {
+			t.Errorf("Reload() error = %v", err)
+		}
+	}
+	wg.Wait()
+
+	select {
+	case <-changes:
+	case <-time.After(5 * time.Second):
+		t.Fatal("timed out waiting for config change")
+	}
+
+	// closing while changes may still be handled must not deadlock
+	if err := v.Close(); err != nil {
+		t.Fatalf("Close() error = %v", err)
+	}
+
+	// nor must resetting while still watching
+	fs = pflag.NewFlagSet("example", pflag.ContinueOnError)
+	fs.String("example", "", "Example flag")
+	fs.Parse([]string{})
+
+	if err := v.Init(fs); err != nil {
+		t.Fatalf("Init() error = %v", err)
+	}
+
+	if err := os.WriteFile(config, []byte("example: before reset\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	v.Reset()
+}
+
+// waitForChange waits for a value matching want to be sent on changes, failing the test if this does not happen before the deadline
+func waitForChange[T comparable](t *testing.T, changes <-chan T, want T) {
+	t.Helper()
+
+	var got T
+	deadline := time.After(5 * time.Second)
+	for {
+		select {
+		case got = <-changes:
+			if got == want {
+				return
+			}
+		case <-deadline:
+			t.Fatalf("timed out waiting for config change, got %v, want %v", got, want)
+		}
+	}
+}